@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// blockRect is a rectangle of rendered columns [left, right) spanning
+// the inclusive rows [top, bottom]
+type blockRect struct {
+	top, bottom int
+	left, right int
+}
+
+func (b blockRect) rows() int {
+	return b.bottom - b.top + 1
+}
+
+func (b blockRect) width() int {
+	return b.right - b.left
+}
+
+func (b blockRect) contains(row int) bool {
+	return row >= b.top && row <= b.bottom
+}
+
+// splitAtRenderCol splits a line at a rendered column. A tab straddling
+// the column is expanded into spaces on either side, and with pad set a
+// line shorter than the column is padded with spaces to reach it.
+func splitAtRenderCol(content string, tabSize, target int, pad bool) (string, string) {
+	runes := []rune(content)
+	renderCol := 0
+	for i, r := range runes {
+		if renderCol >= target {
+			return string(runes[:i]), string(runes[i:])
+		}
+		width := 1
+		if r == TAB {
+			width = tabSize - renderCol%tabSize
+		}
+		if renderCol+width > target {
+			left := string(runes[:i]) + strings.Repeat(" ", target-renderCol)
+			right := strings.Repeat(" ", renderCol+width-target) + string(runes[i+1:])
+			return left, right
+		}
+		renderCol += width
+	}
+	if pad && renderCol < target {
+		return content + strings.Repeat(" ", target-renderCol), ""
+	}
+	return content, ""
+}
+
+// colAtRenderCol returns the rune column of a line at a rendered column,
+// clamped to the end of the line
+func colAtRenderCol(content string, tabSize, target int) int {
+	left, _ := splitAtRenderCol(content, tabSize, target, false)
+	return min(len([]rune(left)), len([]rune(content)))
+}
+
+// handleBlockSelect starts a rectangular selection at the cursor, or clears it
+func (e *Editor) handleBlockSelect() {
+	if e.block != nil {
+		e.clearBlock()
+		e.setStatus("Block selection cleared", 1)
+		return
+	}
+	e.clearMark()
+	e.clearExtraCursors()
+	pos := e.cursorPosition()
+	e.block = &pos
+	e.setStatus("Block selection started", 1)
+}
+
+func (e *Editor) clearBlock() {
+	e.block = nil
+}
+
+// blockRange returns the rectangle between the block anchor and the cursor
+func (e *Editor) blockRange() (blockRect, bool) {
+	if e.block == nil {
+		return blockRect{}, false
+	}
+	anchor := min(e.block.row, e.document.lineCount()-1)
+	content, _ := e.document.getLine(anchor)
+	anchorCol := e.cursor.calculateRenderCol(content, e.document.config.TabSize, e.block.col)
+	content, _ = e.document.getLine(e.cursor.row)
+	cursorCol := e.cursor.calculateRenderCol(content, e.document.config.TabSize, e.cursor.col)
+	return blockRect{
+		top:    min(anchor, e.cursor.row),
+		bottom: max(anchor, e.cursor.row),
+		left:   min(anchorCol, cursorCol),
+		right:  max(anchorCol, cursorCol),
+	}, true
+}
+
+// blockRegion returns the rectangle for rendering, or nil
+func (e *Editor) blockRegion() *blockRect {
+	if b, ok := e.blockRange(); ok {
+		return &b
+	}
+	return nil
+}
+
+// blockText returns the text of each row inside the rectangle, padded
+// with spaces where a line ends before the right edge
+func (e *Editor) blockText(b blockRect) []string {
+	tabSize := e.document.config.TabSize
+	var lines []string
+	for row := b.top; row <= b.bottom; row++ {
+		content, _ := e.document.getLine(row)
+		head, _ := splitAtRenderCol(content, tabSize, b.right, true)
+		_, text := splitAtRenderCol(head, tabSize, b.left, true)
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+// replaceBlock replaces the rectangle on every row with the text for that
+// row, padding short lines up to the left edge only when inserting text
+func (e *Editor) replaceBlock(b blockRect, text func(i int) string) error {
+	tabSize := e.document.config.TabSize
+	for row := b.top; row <= b.bottom; row++ {
+		content, err := e.document.getLine(row)
+		if err != nil {
+			return err
+		}
+		insert := text(row - b.top)
+		head, tail := splitAtRenderCol(content, tabSize, b.right, false)
+		head, _ = splitAtRenderCol(head, tabSize, b.left, insert != "")
+		updated := head + insert + tail
+		if updated == content {
+			continue
+		}
+		err = e.document.replaceLine(row, updated)
+		if err != nil {
+			return err
+		}
+	}
+	e.setDirty()
+	return nil
+}
+
+// selectColumn collapses the block to a zero width column at renderCol
+func (e *Editor) selectColumn(b blockRect, renderCol int) {
+	tabSize := e.document.config.TabSize
+	anchorRow, cursorRow := b.top, b.bottom
+	if e.cursor.row == b.top {
+		anchorRow, cursorRow = b.bottom, b.top
+	}
+	content, _ := e.document.getLine(anchorRow)
+	e.block = &position{anchorRow, colAtRenderCol(content, tabSize, renderCol)}
+	content, _ = e.document.getLine(cursorRow)
+	col := colAtRenderCol(content, tabSize, renderCol)
+	e.cursor.moveTo(cursorRow, col)
+	e.cursor.anchor = col
+}
+
+// copyBlock stores the rectangle in the kill ring
+func (e *Editor) copyBlock() {
+	b, _ := e.blockRange()
+	e.killRing.push(e.blockText(b))
+	e.clearBlock()
+	e.setStatus(fmt.Sprintf("copied block of %d rows", b.rows()), 1)
+}
+
+// cutBlock stores the rectangle in the kill ring and removes it
+func (e *Editor) cutBlock() {
+	b, _ := e.blockRange()
+	e.killRing.push(e.blockText(b))
+	err := e.replaceBlock(b, func(int) string { return "" })
+	if e.handleError("could not cut block", err) {
+		return
+	}
+	e.selectColumn(b, b.left)
+	e.clearBlock()
+	e.setStatus(fmt.Sprintf("cut block of %d rows", b.rows()), 1)
+}
+
+// deleteBlock removes the rectangle, or with a zero width block the
+// column before it, or after it when forward, leaving a zero width block
+// for further typing
+func (e *Editor) deleteBlock(forward bool) {
+	b, _ := e.blockRange()
+	if b.width() == 0 {
+		if forward {
+			b.right++
+		} else if b.left > 0 {
+			b.left--
+		} else {
+			return
+		}
+	}
+	err := e.replaceBlock(b, func(int) string { return "" })
+	if e.handleError("could not delete block", err) {
+		return
+	}
+	e.selectColumn(b, b.left)
+}
+
+// insertInBlock replaces the rectangle with s on every row
+func (e *Editor) insertInBlock(s string) {
+	b, _ := e.blockRange()
+	err := e.replaceBlock(b, func(int) string { return s })
+	if e.handleError("could not insert in block", err) {
+		return
+	}
+	e.selectColumn(b, b.left+len([]rune(s)))
+}
+
+// handleBlockKey applies an editing key to the block selection,
+// reporting whether the key was used
+func (e *Editor) handleBlockKey(r rune) bool {
+	switch r {
+	case ESCAPE:
+		e.clearBlock()
+	case DELETE, BACKSPACE:
+		e.deleteBlock(false)
+	case DEL_KEY:
+		e.deleteBlock(true)
+	case TAB:
+		// tabs would not line up inside the block, so pad to the next stop
+		b, _ := e.blockRange()
+		e.insertInBlock(strings.Repeat(" ", e.document.config.TabSize-b.left%e.document.config.TabSize))
+	case RETURN:
+		e.clearBlock()
+		return false
+	default:
+		if !unicode.IsPrint(r) && r != SPACE {
+			return false
+		}
+		e.insertInBlock(string(r))
+	}
+	return true
+}
+
+// handlePasteBlock pastes the newest kill ring entry as a rectangle with
+// its top left corner at the cursor, replacing the block selection if any
+func (e *Editor) handlePasteBlock() {
+	lines, ok := e.killRing.newest()
+	if !ok {
+		e.setStatus("kill ring is empty", 1)
+		return
+	}
+	b, ok := e.blockRange()
+	if !ok {
+		content, _ := e.document.getLine(e.cursor.row)
+		col := e.cursor.calculateRenderCol(content, e.document.config.TabSize, e.cursor.col)
+		b = blockRect{top: e.cursor.row, left: col, right: col}
+	}
+	b.bottom = b.top + len(lines) - 1
+	for e.document.lineCount() <= b.bottom {
+		err := e.document.addLine(e.document.lineCount(), "")
+		if e.handleError("could not paste block", err) {
+			return
+		}
+	}
+	if e.block != nil {
+		// only the selected rows lose their text
+		selected, _ := e.blockRange()
+		err := e.replaceBlock(selected, func(int) string { return "" })
+		if e.handleError("could not paste block", err) {
+			return
+		}
+		b.right = b.left
+	}
+	err := e.replaceBlock(b, func(i int) string { return lines[i] })
+	if e.handleError("could not paste block", err) {
+		return
+	}
+	e.clearBlock()
+	content, _ := e.document.getLine(b.top)
+	col := colAtRenderCol(content, e.document.config.TabSize, b.left)
+	e.cursor.moveTo(b.top, col)
+	e.cursor.anchor = col
+	e.setStatus(fmt.Sprintf("pasted block of %d rows", len(lines)), 1)
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestSplitAtRenderCol(t *testing.T) {
+	tests := []struct {
+		content     string
+		target      int
+		pad         bool
+		left, right string
+	}{
+		{"hello", 2, false, "he", "llo"},
+		{"hello", 0, false, "", "hello"},
+		{"hello", 5, false, "hello", ""},
+		{"hi", 5, false, "hi", ""},
+		{"hi", 5, true, "hi   ", ""},
+		{"\tx", 2, false, "  ", "  x"},
+		{"\tx", 4, false, "\t", "x"},
+		{"a\tb", 3, false, "a  ", " b"},
+		{"héllo", 2, false, "hé", "llo"},
+		{"日本", 1, false, "日", "本"},
+	}
+	for _, tt := range tests {
+		left, right := splitAtRenderCol(tt.content, 4, tt.target, tt.pad)
+		if left != tt.left || right != tt.right {
+			t.Errorf("splitAtRenderCol(%q, 4, %d, %v) = %q, %q, want %q, %q", tt.content, tt.target, tt.pad, left, right, tt.left, tt.right)
+		}
+	}
+}
+
+func TestColAtRenderCol(t *testing.T) {
+	tests := []struct {
+		content string
+		target  int
+		want    int
+	}{
+		{"hello", 3, 3},
+		{"hello", 9, 5},
+		{"\tx", 4, 1},
+		{"\tx", 5, 2},
+	}
+	for _, tt := range tests {
+		if got := colAtRenderCol(tt.content, 4, tt.target); got != tt.want {
+			t.Errorf("colAtRenderCol(%q, 4, %d) = %d, want %d", tt.content, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+// BRACKET_SCAN_LIMIT is how many lines the matcher looks at on either
+// side of the bracket it is matching
+const BRACKET_SCAN_LIMIT = 500
+
+type bracket struct {
+	pos position
+	r   rune
+}
+
+// bracketAt returns the position of a bracket at col, or just before it,
+// so a bracket is found whether the cursor is on it or right after it
+func (d *Document) bracketAt(row, col int) (position, bool) {
+	content, err := d.getLine(row)
+	if err != nil {
+		return position{}, false
+	}
+	runes := []rune(content)
+	for _, c := range []int{col, col - 1} {
+		if c >= 0 && c < len(runes) && (isOpener(runes[c]) || isCloser(runes[c])) {
+			return position{row, c}, true
+		}
+	}
+	return position{}, false
+}
+
+// codeBrackets lists the brackets in rows [start, end] that are not inside
+// strings or comments, in document order
+func (d *Document) codeBrackets(start, end int) []bracket {
+	lang := d.language()
+	var found []bracket
+	state := d.lexStateAt(start)
+	for row := start; row <= end; row++ {
+		runes := []rune(d.lines[row].content)
+		state = lang.scanLine(runes, state, func(col int, r rune) {
+			if isOpener(r) || isCloser(r) {
+				found = append(found, bracket{position{row, col}, r})
+			}
+		})
+	}
+	return found
+}
+
+// lexStateAt returns the lexer state at the start of a row. States are
+// kept from the top of the document and only rescanned past a change.
+func (d *Document) lexStateAt(row int) lexState {
+	if len(d.lexStates) == 0 {
+		d.lexStates = []lexState{{}}
+	}
+	lang := d.language()
+	for r := len(d.lexStates) - 1; r < row; r++ {
+		runes := []rune(d.lines[r].content)
+		d.lexStates = append(d.lexStates, lang.scanLine(runes, d.lexStates[r], func(int, rune) {}))
+	}
+	return d.lexStates[row]
+}
+
+// openerBefore finds the unmatched opener that a closer typed at pos
+// would close
+func (d *Document) openerBefore(pos position, closer rune) (position, bool) {
+	brackets := d.codeBrackets(max(pos.row-BRACKET_SCAN_LIMIT, 0), pos.row)
+	open := openerFor(closer)
+	depth := 0
+	for i := len(brackets) - 1; i >= 0; i-- {
+		b := brackets[i]
+		if b.pos.row == pos.row && b.pos.col >= pos.col {
+			continue
+		}
+		switch b.r {
+		case closer:
+			depth++
+		case open:
+			if depth == 0 {
+				return b.pos, true
+			}
+			depth--
+		}
+	}
+	return position{}, false
+}
+
+// matchBracket finds the bracket matching the one at pos, skipping
+// brackets in strings and comments
+func (d *Document) matchBracket(pos position) (position, bool) {
+	start := max(pos.row-BRACKET_SCAN_LIMIT, 0)
+	end := min(pos.row+BRACKET_SCAN_LIMIT, d.lineCount()-1)
+	brackets := d.codeBrackets(start, end)
+
+	idx := -1
+	for i, b := range brackets {
+		if b.pos == pos {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return position{}, false
+	}
+
+	open := brackets[idx].r
+	step := 1
+	if isCloser(open) {
+		open = openerFor(open)
+		step = -1
+	}
+	closer := closerFor(open)
+
+	depth := 0
+	for i := idx; i >= 0 && i < len(brackets); i += step {
+		switch brackets[i].r {
+		case open:
+			depth += step
+		case closer:
+			depth -= step
+		}
+		if depth == 0 {
+			return brackets[i].pos, true
+		}
+	}
+	return position{}, false
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestMatchBracket(t *testing.T) {
+	doc := newTestDocument(t, "a.c", `int f(int a[2]) {
+	puts("(}");
+	/* { */
+	g('{', a[(1)]);
+}
+// }`)
+	tests := []struct {
+		pos  position
+		want position
+		ok   bool
+	}{
+		{position{0, 5}, position{0, 14}, true},
+		{position{0, 14}, position{0, 5}, true},
+		{position{0, 11}, position{0, 13}, true},
+		{position{0, 16}, position{4, 0}, true},
+		{position{4, 0}, position{0, 16}, true},
+		{position{3, 2}, position{3, 14}, true},
+		{position{3, 9}, position{3, 13}, true},
+		{position{3, 10}, position{3, 12}, true},
+		// brackets in strings, characters and comments have no match
+		{position{1, 7}, position{}, false},
+		{position{2, 4}, position{}, false},
+		{position{3, 4}, position{}, false},
+		{position{5, 3}, position{}, false},
+		// not a bracket
+		{position{0, 0}, position{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := doc.matchBracket(tt.pos)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("matchBracket(%v) = %v, %v, want %v, %v", tt.pos, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatchBracketAfterEdit(t *testing.T) {
+	doc := newTestDocument(t, "a.c", "f(\nx\n)")
+	steps := []struct {
+		line string
+		ok   bool
+	}{
+		{"x", true},
+		// the lexer states cached past an edit must not be reused
+		{"/* x", false},
+		{"/* x */", true},
+	}
+	for _, s := range steps {
+		if err := doc.replaceLine(1, s.line); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := doc.matchBracket(position{0, 1}); ok != s.ok {
+			t.Errorf("with %q between the brackets: %v, %v, want a match %v", s.line, got, ok, s.ok)
+		}
+	}
+}
+
+func TestBracketAt(t *testing.T) {
+	doc := newTestDocument(t, "", "a(b)")
+	tests := []struct {
+		col  int
+		want position
+		ok   bool
+	}{
+		{1, position{0, 1}, true},
+		{2, position{0, 1}, true},
+		{3, position{0, 3}, true},
+		{4, position{0, 3}, true},
+		{0, position{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := doc.bracketAt(0, tt.col)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("bracketAt(0, %d) = %v, %v, want %v, %v", tt.col, got, ok, tt.want, tt.ok)
+		}
+	}
+}
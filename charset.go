@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const BOM = "\ufeff"
+
+const ErrUnencodable = gtextError("text cannot be encoded")
+
+// decodeLine converts a line read from disk in charset to a string
+func decodeLine(data []byte, charset string) string {
+	if charset == "latin1" {
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+// encodeText converts text to bytes in charset
+func encodeText(text, charset string) ([]byte, error) {
+	if charset != "latin1" {
+		return []byte(text), nil
+	}
+	data := make([]byte, 0, utf8.RuneCountInString(text))
+	for _, r := range text {
+		if r > 0xff {
+			return nil, fmt.Errorf("%w as latin1: %q", ErrUnencodable, r)
+		}
+		data = append(data, byte(r))
+	}
+	return data, nil
+}
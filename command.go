@@ -1,31 +1,257 @@
 package main
 
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	ErrUnknownCommand = gtextError("unknown command")
+	ErrMissingArg     = gtextError("missing argument")
+	ErrTooManyArgs    = gtextError("too many arguments")
+	ErrInvalidArg     = gtextError("invalid argument")
+)
+
+// ArgKind describes how a command argument is parsed and completed
+type ArgKind byte
+
+const (
+	ArgString  ArgKind = iota // a single word
+	ArgInt                    // a base 10 integer
+	ArgPath                   // a file path, completed from disk
+	ArgSetting                // a key=value configuration pair
+	ArgRest                   // the remainder of the command line, verbatim
+)
+
+type Arg struct {
+	name     string
+	kind     ArgKind
+	optional bool
+}
+
+// cmdArgs holds parsed arguments by name; ints are stored as int,
+// everything else as string
+type cmdArgs map[string]any
+
+func (a cmdArgs) has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+func (a cmdArgs) str(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+func (a cmdArgs) num(name string) int {
+	n, _ := a[name].(int)
+	return n
+}
+
 type Command struct {
-	key    rune
-	name   string
-	desc   string
-	action func()
+	name    string
+	aliases []string
+	key     rune
+	desc    string
+	args    []Arg
+	action  func()
+	exec    func(args cmdArgs) error
+}
+
+// call runs the command, preferring exec when the command takes arguments
+func (c Command) call(args cmdArgs) error {
+	if c.exec != nil {
+		if args == nil {
+			args = cmdArgs{}
+		}
+		return c.exec(args)
+	}
+	if c.action != nil {
+		c.action()
+	}
+	return nil
+}
+
+// requiresArgs reports whether the command cannot run without arguments
+func (c Command) requiresArgs() bool {
+	for _, arg := range c.args {
+		if !arg.optional {
+			return true
+		}
+	}
+	return false
+}
+
+// usage returns a one line synopsis such as "write [file]"
+func (c Command) usage() string {
+	parts := []string{c.name}
+	for _, arg := range c.args {
+		if arg.optional {
+			parts = append(parts, "["+arg.name+"]")
+		} else {
+			parts = append(parts, "<"+arg.name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseArgs splits the argument text according to the declared arguments
+func (c Command) parseArgs(text string) (cmdArgs, error) {
+	args := cmdArgs{}
+	rest := strings.TrimSpace(text)
+	for _, arg := range c.args {
+		if rest == "" {
+			if !arg.optional {
+				return nil, fmt.Errorf("%w %s: usage: %s", ErrMissingArg, arg.name, c.usage())
+			}
+			continue
+		}
+
+		var word string
+		if arg.kind == ArgRest {
+			word, rest = rest, ""
+		} else {
+			word, rest, _ = strings.Cut(rest, " ")
+			rest = strings.TrimSpace(rest)
+		}
+
+		switch arg.kind {
+		case ArgInt:
+			n, err := strconv.Atoi(word)
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: %q is not a number", ErrInvalidArg, arg.name, word)
+			}
+			args[arg.name] = n
+		case ArgSetting:
+			if !strings.Contains(word, "=") {
+				return nil, fmt.Errorf("%w %s: expected key=value, got %q", ErrInvalidArg, arg.name, word)
+			}
+			args[arg.name] = word
+		default:
+			args[arg.name] = word
+		}
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("%w: %q: usage: %s", ErrTooManyArgs, rest, c.usage())
+	}
+	return args, nil
 }
 
 type CommandRegistry struct {
-	cmds  map[rune]Command
-	order []rune
+	cmds     map[string]Command
+	bindings map[rune]string
+	aliases  map[string]string
+	order    []string
+	onError  func(err error)
 }
 
 func (cr *CommandRegistry) register(cmd Command) {
 	if cr.cmds == nil {
-		cr.cmds = make(map[rune]Command)
+		cr.cmds = make(map[string]Command)
+		cr.bindings = make(map[rune]string)
+		cr.aliases = make(map[string]string)
 	}
-	if _, exists := cr.cmds[cmd.key]; !exists {
-		cr.order = append(cr.order, cmd.key)
+	if old, exists := cr.cmds[cmd.name]; exists {
+		delete(cr.bindings, old.key)
+	} else {
+		cr.order = append(cr.order, cmd.name)
 	}
-	cr.cmds[cmd.key] = cmd
+	cr.cmds[cmd.name] = cmd
+	if cmd.key != 0 {
+		cr.bindings[cmd.key] = cmd.name
+	}
+	for _, alias := range cmd.aliases {
+		cr.aliases[alias] = cmd.name
+	}
+}
+
+// lookup finds a command by name or alias
+func (cr *CommandRegistry) lookup(name string) (Command, bool) {
+	if canonical, ok := cr.aliases[name]; ok {
+		name = canonical
+	}
+	cmd, ok := cr.cmds[name]
+	return cmd, ok
 }
 
+// execute runs the command bound to key, if any
 func (cr *CommandRegistry) execute(key rune) bool {
-	if cmd, ok := cr.cmds[key]; ok {
-		cmd.action()
-		return true
+	name, ok := cr.bindings[key]
+	if !ok {
+		return false
 	}
-	return false
+	return cr.run(name)
+}
+
+// run executes the command registered under name without arguments
+func (cr *CommandRegistry) run(name string) bool {
+	cmd, ok := cr.lookup(name)
+	if !ok {
+		return false
+	}
+	cr.report(cmd.call(nil))
+	return true
+}
+
+// invoke parses and runs a command line such as "write other.txt"
+func (cr *CommandRegistry) invoke(line string) error {
+	name, rest := splitCommandLine(line)
+	if name == "" {
+		return nil
+	}
+	cmd, ok := cr.lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, name)
+	}
+	args, err := cmd.parseArgs(rest)
+	if err != nil {
+		return err
+	}
+	return cmd.call(args)
+}
+
+func (cr *CommandRegistry) report(err error) {
+	if err != nil && cr.onError != nil {
+		cr.onError(err)
+	}
+}
+
+// all returns every registered command in registration order
+func (cr *CommandRegistry) all() []Command {
+	cmds := make([]Command, 0, len(cr.order))
+	for _, name := range cr.order {
+		cmds = append(cmds, cr.cmds[name])
+	}
+	return cmds
+}
+
+// names returns all command names and aliases, sorted
+func (cr *CommandRegistry) names() []string {
+	names := slices.Clone(cr.order)
+	for alias := range cr.aliases {
+		names = append(names, alias)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// keyFor returns the key bound to the named command, or 0 if it is unbound
+func (cr *CommandRegistry) keyFor(name string) rune {
+	if cmd, ok := cr.cmds[name]; ok {
+		return cmd.key
+	}
+	return 0
+}
+
+// splitCommandLine separates the command name from its arguments;
+// a leading "!" is a command on its own so "!ls" works without a space
+func splitCommandLine(line string) (name, rest string) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "!") {
+		return "!", line[1:]
+	}
+	name, rest, _ = strings.Cut(line, " ")
+	return name, rest
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	cmd := Command{
+		name: "test",
+		args: []Arg{
+			{name: "count", kind: ArgInt},
+			{name: "setting", kind: ArgSetting, optional: true},
+			{name: "rest", kind: ArgRest, optional: true},
+		},
+	}
+	tests := []struct {
+		text string
+		want cmdArgs
+		err  error
+	}{
+		{"3", cmdArgs{"count": 3}, nil},
+		{"  3  ", cmdArgs{"count": 3}, nil},
+		{"3 tab_size=2", cmdArgs{"count": 3, "setting": "tab_size=2"}, nil},
+		{"3  a=b  the rest  of it", cmdArgs{"count": 3, "setting": "a=b", "rest": "the rest  of it"}, nil},
+		{"", nil, ErrMissingArg},
+		{"three", nil, ErrInvalidArg},
+		{"3 tab_size", nil, ErrInvalidArg},
+	}
+	for _, tt := range tests {
+		got, err := cmd.parseArgs(tt.text)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("parseArgs(%q) error = %v, want %v", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !maps.Equal(got, tt.want) {
+			t.Errorf("parseArgs(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+
+	single := Command{name: "write", args: []Arg{{name: "file", kind: ArgPath, optional: true}}}
+	if _, err := single.parseArgs("a.txt b.txt"); !errors.Is(err, ErrTooManyArgs) {
+		t.Errorf("parseArgs with an extra word: %v, want %v", err, ErrTooManyArgs)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line, name, rest string
+	}{
+		{"write", "write", ""},
+		{"write a.txt", "write", "a.txt"},
+		{"  set tab_size=2 user ", "set", "tab_size=2 user"},
+		{"!ls -l", "!", "ls -l"},
+		{"! ls", "!", " ls"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		name, rest := splitCommandLine(tt.line)
+		if name != tt.name || rest != tt.rest {
+			t.Errorf("splitCommandLine(%q) = %q, %q, want %q, %q", tt.line, name, rest, tt.name, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ErrUnsavedChanges = gtextError("unsaved changes, save them first")
+	ErrEmptyFileName  = gtextError("no file name given")
+)
+
+// registerExCommands registers the commands that take arguments and are
+// typed on the command line rather than bound to keys
+func (e *Editor) registerExCommands() {
+	e.commands.register(Command{
+		name:    "write",
+		aliases: []string{"w"},
+		desc:    "Write the file, or a copy to another file",
+		args:    []Arg{{name: "file", kind: ArgPath, optional: true}},
+		exec:    e.execWrite,
+	})
+
+	e.commands.register(Command{
+		name:    "edit",
+		aliases: []string{"e"},
+		desc:    "Open a file",
+		args:    []Arg{{name: "file", kind: ArgPath, optional: true}},
+		exec:    e.execEdit,
+	})
+
+	e.commands.register(Command{
+		name: "saveas",
+		desc: "Save under a new file name",
+		args: []Arg{{name: "file", kind: ArgPath, optional: true}},
+		exec: e.execSaveAs,
+	})
+
+	e.commands.register(Command{
+		name: "goto",
+		desc: "Go to line[:col], +/-N lines or N%",
+		args: []Arg{{name: "location", kind: ArgString}},
+		exec: e.execGoto,
+	})
+
+	e.commands.register(Command{
+		name: "set",
+		desc: "Change a setting for this session, or save it with scope user or filetype",
+		args: []Arg{{name: "setting", kind: ArgSetting}, {name: "scope", kind: ArgString, optional: true}},
+		exec: e.execSet,
+	})
+
+	e.commands.register(Command{
+		name: "settings",
+		desc: "Show every setting and where its value came from",
+		exec: e.execSettings,
+	})
+
+	e.commands.register(Command{
+		name: "record",
+		desc: "Start recording a macro, or stop the current recording",
+		args: []Arg{{name: "macro", kind: ArgString, optional: true}},
+		exec: e.execRecord,
+	})
+
+	e.commands.register(Command{
+		name: "play",
+		desc: "Play a macro, optionally several times",
+		args: []Arg{{name: "macro", kind: ArgString, optional: true}, {name: "count", kind: ArgInt, optional: true}},
+		exec: e.execPlay,
+	})
+
+	e.commands.register(Command{
+		name: "bind-macro",
+		desc: "Bind a key such as Alt-1 to play a macro",
+		args: []Arg{{name: "key", kind: ArgString}, {name: "macro", kind: ArgString, optional: true}},
+		exec: e.execBindMacro,
+	})
+
+	e.commands.register(Command{
+		name: "trim-whitespace",
+		desc: "Strip trailing whitespace from every line",
+		exec: e.execTrimWhitespace,
+	})
+
+	e.commands.register(Command{
+		name: "final-newline",
+		desc: "Remove blank lines at the end so the file ends in one newline",
+		exec: e.execFinalNewline,
+	})
+
+	e.commands.register(Command{
+		name: "retab",
+		desc: "Convert indentation to tabs or spaces following expand_tabs",
+		exec: e.execRetab,
+	})
+
+	e.commands.register(Command{
+		name: "sort",
+		desc: "Sort marked or all lines; options r reverse, n numeric, v natural, i ignore case, u unique",
+		args: []Arg{{name: "options", kind: ArgString, optional: true}},
+		exec: e.execSort,
+	})
+
+	e.commands.register(Command{
+		name: "uniq",
+		desc: "Remove duplicate lines from marked or all lines",
+		exec: e.execUniq,
+	})
+
+	e.commands.register(Command{
+		name: "reverse",
+		desc: "Reverse the order of marked or all lines",
+		exec: e.execReverse,
+	})
+
+	e.commands.register(Command{
+		name:    "keep",
+		aliases: []string{"g"},
+		desc:    "Keep only marked or all lines matching a pattern",
+		args:    []Arg{{name: "pattern", kind: ArgRest}},
+		exec:    e.execKeep,
+	})
+
+	e.commands.register(Command{
+		name:    "drop",
+		aliases: []string{"v"},
+		desc:    "Delete marked or all lines matching a pattern",
+		args:    []Arg{{name: "pattern", kind: ArgRest}},
+		exec:    e.execDrop,
+	})
+
+	e.commands.register(Command{
+		name: "!",
+		desc: "Run a shell command",
+		args: []Arg{{name: "command", kind: ArgRest}},
+		exec: e.execShell,
+	})
+
+	e.commands.register(Command{
+		name:    "pipe",
+		aliases: []string{"|"},
+		desc:    "Replace marked or all lines with the output of a shell command run on them",
+		args:    []Arg{{name: "command", kind: ArgRest}},
+		exec:    e.execPipe,
+	})
+
+	e.commands.register(Command{
+		name:    "read",
+		aliases: []string{"r"},
+		desc:    "Insert the output of a shell command below the cursor",
+		args:    []Arg{{name: "command", kind: ArgRest}},
+		exec:    e.execRead,
+	})
+}
+
+func (e *Editor) handleCommandLine() {
+	e.openCommandLine("")
+}
+
+func (e *Editor) openCommandLine(initial string) {
+	e.openPrompt(":", initial, "command", e.completeCommandLine, e.runCommandLine)
+}
+
+func (e *Editor) runCommandLine(line string) {
+	err := e.commands.invoke(line)
+	e.commands.report(err)
+}
+
+// completeCommandLine completes command names, and then each argument
+// according to its declared kind
+func (e *Editor) completeCommandLine(input string) []string {
+	name, rest := splitCommandLine(input)
+	if !strings.Contains(input, " ") && name != "!" {
+		var matches []string
+		for _, n := range e.commands.names() {
+			if strings.HasPrefix(n, name) {
+				matches = append(matches, n)
+			}
+		}
+		return matches
+	}
+
+	cmd, ok := e.commands.lookup(name)
+	if !ok {
+		return nil
+	}
+	words := strings.Fields(rest)
+	current := ""
+	if len(words) > 0 && !strings.HasSuffix(rest, " ") {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	if len(words) >= len(cmd.args) {
+		return nil
+	}
+	head := input[:len(input)-len(current)]
+
+	var candidates []string
+	switch cmd.args[len(words)].kind {
+	case ArgPath:
+		candidates = completePath(current)
+	case ArgSetting:
+		for _, key := range configKeys {
+			if strings.HasPrefix(key, current) {
+				candidates = append(candidates, key+"=")
+			}
+		}
+	}
+	for i, c := range candidates {
+		candidates[i] = head + c
+	}
+	return candidates
+}
+
+func (e *Editor) execWrite(args cmdArgs) error {
+	if !args.has("file") {
+		e.handleSave()
+		return nil
+	}
+	path := expandPath(args.str("file"))
+	n, err := e.document.WriteToDisk(path)
+	if err != nil {
+		return err
+	}
+	e.setStatus(fmt.Sprintf("Wrote %d bytes to %s", n, path), 2)
+	return nil
+}
+
+func (e *Editor) execEdit(args cmdArgs) error {
+	if !args.has("file") {
+		e.handleOpen()
+		return nil
+	}
+	e.open(args.str("file"))
+	return nil
+}
+
+func (e *Editor) execSaveAs(args cmdArgs) error {
+	if !args.has("file") {
+		e.handleSaveAs()
+		return nil
+	}
+	e.saveAs(args.str("file"))
+	return nil
+}
+
+func (e *Editor) execGoto(args cmdArgs) error {
+	return e.gotoLocation(args.str("location"))
+}
+
+func (e *Editor) execSet(args cmdArgs) error {
+	key, val, _ := strings.Cut(args.str("setting"), "=")
+	key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+	err := checkSetting(key, val)
+	if err != nil {
+		return err
+	}
+	if args.has("scope") {
+		return e.persistSetting(key, val, args.str("scope"))
+	}
+	// session settings win over every file and apply to files opened later
+	e.layers.forget(key)
+	e.layers.session = append(e.layers.session, setting{key: key, value: val, origin: "set"})
+	e.document.config = e.layers.resolve(e.document.fileName)
+	e.applyConfig()
+	e.setStatus(fmt.Sprintf("%s=%s", key, val), 2)
+	return nil
+}
+
+// execSettings lists the settings of the document in the message area
+func (e *Editor) execSettings(args cmdArgs) error {
+	e.message = e.document.config.describe()
+	return nil
+}
+
+// applyConfig propagates configuration changes to the components
+// that copy or cache settings
+func (e *Editor) applyConfig() {
+	e.document.rerender()
+	e.view.scrollMargin = e.document.config.ScrollMargin
+	e.killRing.resize(e.document.config.KillRingSize)
+}
+
+// reloadConfig re-resolves the settings of the document when a config
+// file changed on disk
+func (e *Editor) reloadConfig() {
+	if !e.document.config.outdated() {
+		return
+	}
+	e.layers.loadUser()
+	e.document.config = e.layers.resolve(e.document.fileName)
+	e.applyConfig()
+	e.message = e.document.config.warnings
+	if len(e.message) > 0 {
+		e.setStatus(fmt.Sprintf("configuration reloaded with %d problems", len(e.message)), 3)
+		return
+	}
+	e.setStatus("configuration reloaded", 2)
+}
+
+// persistSetting saves a setting to the user config file, for all files or
+// in the section for the language of the document, and applies it
+func (e *Editor) persistSetting(key, val, scope string) error {
+	var section string
+	switch scope {
+	case "user":
+	case "filetype":
+		section = FILETYPE_SECTION + e.document.language().name
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+	}
+	if e.layers.noFiles {
+		return ErrNoConfig
+	}
+	if e.layers.userPath == "" {
+		return ErrNoHomeDir
+	}
+	err := saveSetting(e.layers.userPath, section, key, val)
+	if err != nil {
+		return fmt.Errorf("could not save setting: %w", err)
+	}
+
+	// the saved value replaces any set for the session
+	e.layers.forget(key)
+	e.layers.loadUser()
+	e.document.config = e.layers.resolve(e.document.fileName)
+	e.applyConfig()
+
+	where := displayPath(e.layers.userPath)
+	if section != "" {
+		where += fmt.Sprintf(" [%s]", section)
+	}
+	if e.document.config.get(key) != val {
+		e.setStatus(fmt.Sprintf("saved %s=%s to %s, overridden by %s", key, val, where, e.document.config.origin(key)), 4)
+		return nil
+	}
+	e.setStatus(fmt.Sprintf("saved %s=%s to %s", key, val, where), 2)
+	return nil
+}
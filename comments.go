@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+)
+
+const ErrNoCommentSyntax = gtextError("no comment syntax for this file type")
+
+// toggleComment comments out rows [start, end], or uncomments them if
+// every non-blank line is already commented. Line comments are inserted
+// after the indentation all lines of the range share so they stay aligned;
+// languages without line comments get each line wrapped in a block comment.
+func (d *Document) toggleComment(start, end int) error {
+	if start < 0 || end >= d.lineCount() || start > end {
+		return ErrRowOutOfBounds
+	}
+	lang := d.language()
+	open, close := lang.lineComment, ""
+	if open == "" {
+		open, close = lang.blockComment[0], lang.blockComment[1]
+	}
+	if open == "" {
+		return ErrNoCommentSyntax
+	}
+
+	commented := true
+	var indents []string
+	for row := start; row <= end; row++ {
+		content := d.lines[row].content
+		if isBlank(content) {
+			continue
+		}
+		trimmed := strings.TrimSpace(content)
+		if !strings.HasPrefix(trimmed, open) || !strings.HasSuffix(trimmed, close) {
+			commented = false
+		}
+		indents = append(indents, leadingWhitespace(content))
+	}
+	if len(indents) == 0 {
+		return nil
+	}
+	// mixed tabs and spaces only share the whitespace they agree on
+	indent := commonPrefix(indents)
+
+	for row := start; row <= end; row++ {
+		content := d.lines[row].content
+		if isBlank(content) {
+			continue
+		}
+		var newContent string
+		if commented {
+			newContent = uncommentLine(content, open, close)
+		} else {
+			newContent = commentLine(content, indent, open, close)
+		}
+		err := d.replaceLine(row, newContent)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commentLine inserts the comment markers after indent, a prefix of content
+func commentLine(content, indent, open, close string) string {
+	line := indent + open + " " + content[len(indent):]
+	if close != "" {
+		line += " " + close
+	}
+	return line
+}
+
+// uncommentLine removes the comment markers and the space next to them
+func uncommentLine(content, open, close string) string {
+	indent := leadingWhitespace(content)
+	body := strings.TrimPrefix(content[len(indent):], open)
+	body = strings.TrimPrefix(body, " ")
+	if close != "" {
+		body = strings.TrimRightFunc(body, func(r rune) bool { return r == ' ' || r == '\t' })
+		body = strings.TrimSuffix(body, close)
+		body = strings.TrimSuffix(body, " ")
+	}
+	return indent + body
+}
+
+func (e *Editor) handleToggleComment() {
+	r := e.selectedLines()
+	e.shiftLines(r, e.document.toggleComment)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestToggleComment(t *testing.T) {
+	tests := []struct {
+		name, file string
+		text       string
+		start, end int
+		want       []string
+	}{
+		{"comment", "a.py", "x = 1\ny = 2", 0, 1, []string{"# x = 1", "# y = 2"}},
+		{"uncomment", "a.py", "# x = 1\n#y = 2", 0, 1, []string{"x = 1", "y = 2"}},
+		{"mixed comments and code", "a.py", "# x = 1\ny = 2", 0, 1, []string{"# # x = 1", "# y = 2"}},
+		{"at the shared indentation", "a.c", "\tif (x) {\n\t\tf();\n\t}", 0, 2, []string{"\t// if (x) {", "\t// \tf();", "\t// }"}},
+		{"mixed tabs and spaces", "a.py", "  x = 1\n\ty = 2", 0, 1, []string{"#   x = 1", "# \ty = 2"}},
+		{"spaces then a tab", "a.py", "    x = 1\n  \ty = 2", 0, 1, []string{"  #   x = 1", "  # \ty = 2"}},
+		{"skips blank lines", "a.py", "x\n\n  \ny", 0, 3, []string{"# x", "", "  ", "# y"}},
+		{"uncomment keeps indentation", "a.c", "  // f();\n  //g();", 0, 1, []string{"  f();", "  g();"}},
+		{"block comments", "a.css", "a {}\n  b {}", 0, 1, []string{"/* a {} */", "/*   b {} */"}},
+		{"block uncomment", "a.css", "/* a {} */\n/*   b {} */", 0, 1, []string{"a {}", "  b {}"}},
+		{"only the range", "a.py", "x\ny\nz", 1, 1, []string{"x", "# y", "z"}},
+		{"all blank", "a.py", "\n  ", 0, 1, []string{"", "  "}},
+	}
+	for _, tt := range tests {
+		doc := newTestDocument(t, tt.file, tt.text)
+		err := doc.toggleComment(tt.start, tt.end)
+		if err != nil || !slices.Equal(docLines(doc), tt.want) {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, docLines(doc), err, tt.want)
+		}
+	}
+}
+
+func TestToggleCommentErrors(t *testing.T) {
+	doc := newTestDocument(t, "a.txt", "x")
+	if err := doc.toggleComment(0, 0); !errors.Is(err, ErrNoCommentSyntax) {
+		t.Errorf("plain text: %v, want %v", err, ErrNoCommentSyntax)
+	}
+	doc = newTestDocument(t, "a.py", "x")
+	if err := doc.toggleComment(0, 1); !errors.Is(err, ErrRowOutOfBounds) {
+		t.Errorf("past the end: %v, want %v", err, ErrRowOutOfBounds)
+	}
+}
@@ -4,80 +4,240 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const CONFIGFILE string = ".gtext.conf"
 
+const (
+	ErrUnknownConfigKey   = gtextError("unknown config key")
+	ErrInvalidConfigValue = gtextError("invalid config value")
+)
+
 type Config struct {
 	ShowLineNumbers bool
 	ExpandTabs      bool
 	TabSize         int
 	ScrollMargin    int
+	AutoIndent      bool
+	AutoPairs       bool
+	KillRingSize    int
+	FormatOnSave    bool
+	// whitespace fixes applied on save
+	TrimTrailingWhitespace bool
+	TrimSkipCursorLine     bool
+	KeepMarkdownBreaks     bool
+	FinalNewline           bool
+	ConvertIndentation     bool
+	InsertFinalNewline     bool
+	// file format
+	EndOfLine     string
+	Charset       string
+	MaxLineLength int
+	// AutoPairsByLanguage overrides AutoPairs for a language, by name
+	AutoPairsByLanguage map[string]bool
+	// Formatters maps a language name to the shell command that formats it
+	Formatters map[string]string
+
+	// origins records which layer set each key, see ConfigLayers
+	origins map[string]string
+	// warnings are the problems found in the config files it was read from
+	warnings []string
+	// stamps holds the modification time of each config file it was read
+	// from, and the zero time for places where one did not exist
+	stamps map[string]time.Time
 }
 
 func DefaultConfig() *Config {
 	cfg := Config{
-		ShowLineNumbers: true,
-		ExpandTabs:      false,
-		TabSize:         4,
-		ScrollMargin:    5,
+		ShowLineNumbers:    true,
+		ExpandTabs:         false,
+		TabSize:            4,
+		ScrollMargin:       5,
+		AutoIndent:         true,
+		AutoPairs:          true,
+		KillRingSize:       16,
+		FormatOnSave:       true,
+		KeepMarkdownBreaks: true,
+		InsertFinalNewline: true,
+		EndOfLine:          "lf",
+		Charset:            "utf-8",
+		Formatters: map[string]string{
+			"go":         "gofmt",
+			"javascript": "prettier --parser typescript",
+			"css":        "prettier --parser css",
+			"json":       "prettier --parser json",
+			"python":     "black -q -",
+			"shell":      "shfmt",
+		},
 	}
 	return &cfg
 }
 
-func loadConfig() *Config {
-	cfg := DefaultConfig()
-
-	home, err := os.UserHomeDir()
+// set assigns a single option from its config file representation
+func (c *Config) set(key, val string) error {
+	err := checkSetting(key, val)
 	if err != nil {
-		return cfg
+		return err
+	}
+	// per-language overrides, e.g. auto_pairs.markdown=false
+	if lang, ok := strings.CutPrefix(key, "auto_pairs."); ok {
+		if c.AutoPairsByLanguage == nil {
+			c.AutoPairsByLanguage = make(map[string]bool)
+		}
+		c.AutoPairsByLanguage[lang], _ = strconv.ParseBool(val)
+		return nil
+	}
+	// formatters per language, e.g. formatter.go=gofmt
+	if lang, ok := strings.CutPrefix(key, "formatter."); ok {
+		if c.Formatters == nil {
+			c.Formatters = make(map[string]string)
+		}
+		c.Formatters[lang] = val
+		return nil
 	}
 
-	file, err := os.Open(filepath.Join(home, CONFIGFILE))
-	if err != nil {
-		return cfg
+	switch field := c.field(key).(type) {
+	case *bool:
+		*field, _ = strconv.ParseBool(val)
+	case *int:
+		*field, _ = strconv.Atoi(val)
+	case *string:
+		*field = val
 	}
-	defer file.Close()
+	return nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "#") || line == "" {
-			continue
-		}
+// get returns the config file representation of a single option
+func (c *Config) get(key string) string {
+	if lang, ok := strings.CutPrefix(key, "auto_pairs."); ok {
+		return strconv.FormatBool(c.autoPairsFor(lang))
+	}
+	if lang, ok := strings.CutPrefix(key, "formatter."); ok {
+		return c.Formatters[lang]
+	}
+	switch field := c.field(key).(type) {
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *int:
+		return strconv.Itoa(*field)
+	case *string:
+		return *field
+	}
+	return ""
+}
+
+// field returns a pointer to the field behind a key of configSchema
+func (c *Config) field(key string) any {
+	switch key {
+	case "show_line_numbers":
+		return &c.ShowLineNumbers
+	case "expand_tabs":
+		return &c.ExpandTabs
+	case "tab_size":
+		return &c.TabSize
+	case "scroll_margin":
+		return &c.ScrollMargin
+	case "auto_indent":
+		return &c.AutoIndent
+	case "auto_pairs":
+		return &c.AutoPairs
+	case "kill_ring_size":
+		return &c.KillRingSize
+	case "format_on_save":
+		return &c.FormatOnSave
+	case "trim_trailing_whitespace":
+		return &c.TrimTrailingWhitespace
+	case "trim_skip_cursor_line":
+		return &c.TrimSkipCursorLine
+	case "keep_markdown_breaks":
+		return &c.KeepMarkdownBreaks
+	case "final_newline":
+		return &c.FinalNewline
+	case "convert_indentation":
+		return &c.ConvertIndentation
+	case "insert_final_newline":
+		return &c.InsertFinalNewline
+	case "end_of_line":
+		return &c.EndOfLine
+	case "charset":
+		return &c.Charset
+	case "max_line_length":
+		return &c.MaxLineLength
+	}
+	return nil
+}
+
+// autoPairsFor reports whether brackets and quotes are auto-closed in a language
+func (c *Config) autoPairsFor(lang string) bool {
+	if b, ok := c.AutoPairsByLanguage[lang]; ok {
+		return b
+	}
+	return c.AutoPairs
+}
+
+var lineEndings = map[string]string{"lf": "\n", "crlf": "\r\n", "cr": "\r"}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
+// lineEnding returns the text written at the end of each line
+func (c *Config) lineEnding() string {
+	if ending, ok := lineEndings[c.EndOfLine]; ok {
+		return ending
+	}
+	return "\n"
+}
+
+// formatterFor returns the command that formats a language, if formatting
+// on save is enabled and one is configured. An empty command turns the
+// formatter off, and a default one is skipped when it is not installed.
+func (c *Config) formatterFor(lang string) (string, bool) {
+	if !c.FormatOnSave {
+		return "", false
+	}
+	cmd := c.Formatters[lang]
+	if strings.TrimSpace(cmd) == "" {
+		return "", false
+	}
+	if c.origin("formatter."+lang) == "default" {
+		program := strings.Fields(cmd)[0]
+		if _, err := exec.LookPath(program); err != nil {
+			return "", false
 		}
+	}
+	return cmd, true
+}
 
-		key := strings.TrimSpace(parts[0])
-		val := strings.TrimSpace(parts[1])
-
-		switch key {
-		case "show_line_numbers":
-			if b, err := strconv.ParseBool(val); err == nil {
-				cfg.ShowLineNumbers = b
-			}
-		case "expand_tabs":
-			if b, err := strconv.ParseBool(val); err == nil {
-				cfg.ExpandTabs = b
-			}
-		case "tab_size":
-			if ts, err := strconv.Atoi(val); err == nil && ts > 0 {
-				cfg.TabSize = ts
-			}
-		case "scroll_margin":
-			if sm, err := strconv.Atoi(val); err == nil && sm >= 0 {
-				cfg.ScrollMargin = sm
-			}
+// indentUnit returns the text inserted for one level of indentation
+func (c *Config) indentUnit() string {
+	if c.ExpandTabs {
+		return strings.Repeat(" ", c.TabSize)
+	}
+	return "\t"
+}
+
+// promptBool asks until the user enters true or false
+func promptBool(prompt string, defaultValue bool) bool {
+	for {
+		input := promptUser(prompt+" (true/false)", fmt.Sprintf("%t", defaultValue))
+		if b, err := strconv.ParseBool(input); err == nil {
+			return b
 		}
+		fmt.Println("Invalid input. Please enter 'true' or 'false'.")
 	}
+}
 
-	return cfg
+// promptInt asks until the user enters a number no smaller than least
+func promptInt(prompt string, defaultValue, least int) int {
+	for {
+		input := promptUser(fmt.Sprintf("%s (number >= %d)", prompt, least), strconv.Itoa(defaultValue))
+		if n, err := strconv.Atoi(input); err == nil && n >= least {
+			return n
+		}
+		fmt.Printf("Invalid input. Please enter a number %d or greater.\n", least)
+	}
 }
 
 func promptUser(prompt string, defaultValue string) string {
@@ -105,49 +265,17 @@ func initConfig() {
 
 	defaults := DefaultConfig()
 
-	var showLineNumbersBool bool
-	for {
-		prompt := "Show line numbers (true/false)"
-		input := promptUser(prompt, fmt.Sprintf("%t", defaults.ShowLineNumbers))
-		if b, err := strconv.ParseBool(input); err == nil {
-			showLineNumbersBool = b
-			break // Valid boolean, exit loop
-		}
-		fmt.Println("Invalid input. Please enter 'true' or 'false'.")
-	}
-
-	var expandTabsBool bool
-	for {
-		prompt := "Expand tabs to spaces (true/false)"
-		input := promptUser(prompt, fmt.Sprintf("%t", defaults.ExpandTabs))
-		if b, err := strconv.ParseBool(input); err == nil {
-			expandTabsBool = b
-			break
-		}
-		fmt.Println("Invalid input. Please enter 'true' or 'false'.")
-	}
-
-	var tabSizeInt int
-	for {
-		prompt := "Tab size (number > 0)"
-		input := promptUser(prompt, fmt.Sprintf("%d", defaults.TabSize))
-		if ts, err := strconv.Atoi(input); err == nil && ts > 0 {
-			tabSizeInt = ts
-			break
-		}
-		fmt.Println("Invalid input. Please enter a number greater than 0.")
-	}
-
-	var scrollMarginInt int
-	for {
-		prompt := "Scroll margin (number >= 0)"
-		input := promptUser(prompt, fmt.Sprintf("%d", defaults.ScrollMargin))
-		if sm, err := strconv.Atoi(input); err == nil && sm >= 0 {
-			scrollMarginInt = sm
-			break
-		}
-		fmt.Println("Invalid input. Please enter a number 0 or greater.")
-	}
+	showLineNumbersBool := promptBool("Show line numbers", defaults.ShowLineNumbers)
+	expandTabsBool := promptBool("Expand tabs to spaces", defaults.ExpandTabs)
+	tabSizeInt := promptInt("Tab size", defaults.TabSize, 1)
+	scrollMarginInt := promptInt("Scroll margin", defaults.ScrollMargin, 0)
+	autoIndentBool := promptBool("Auto-indent new lines", defaults.AutoIndent)
+	autoPairsBool := promptBool("Auto-close brackets and quotes", defaults.AutoPairs)
+	killRingSizeInt := promptInt("Kill ring size", defaults.KillRingSize, 1)
+	formatOnSaveBool := promptBool("Run configured formatters on save", defaults.FormatOnSave)
+	trimTrailingBool := promptBool("Trim trailing whitespace on save", defaults.TrimTrailingWhitespace)
+	finalNewlineBool := promptBool("Remove extra blank lines at the end on save", defaults.FinalNewline)
+	convertIndentationBool := promptBool("Convert indentation to tabs or spaces on save", defaults.ConvertIndentation)
 
 	configContent := fmt.Sprintf(
 		`# gtext config file
@@ -155,7 +283,15 @@ show_line_numbers=%t
 expand_tabs=%t
 tab_size=%d
 scroll_margin=%d
-`, showLineNumbersBool, expandTabsBool, tabSizeInt, scrollMarginInt)
+auto_indent=%t
+auto_pairs=%t
+kill_ring_size=%d
+format_on_save=%t
+trim_trailing_whitespace=%t
+final_newline=%t
+convert_indentation=%t
+`, showLineNumbersBool, expandTabsBool, tabSizeInt, scrollMarginInt, autoIndentBool, autoPairsBool, killRingSizeInt, formatOnSaveBool,
+		trimTrailingBool, finalNewlineBool, convertIndentationBool)
 
 	err = os.WriteFile(configPath, []byte(configContent), 0644)
 	if err != nil {
@@ -165,3 +301,49 @@ scroll_margin=%d
 
 	fmt.Printf("Created config at %s\n", configPath)
 }
+
+// runConfig runs `gtext config [check|print|defaults] [file]` and returns
+// the exit code. Without a subcommand it starts the interactive setup.
+func runConfig(args []string, layers *ConfigLayers) int {
+	if len(args) == 0 {
+		initConfig()
+		return 0
+	}
+	if len(args) > 2 {
+		fmt.Fprintf(os.Stderr, "Error: Too many arguments: %v\n", args[2:])
+		return 1
+	}
+	fileName := ""
+	if len(args) == 2 {
+		fileName = args[1]
+	}
+
+	switch args[0] {
+	case "check":
+		// settings are resolved for fileName to find its project file
+		warnings := layers.resolve(fileName).warnings
+		for _, w := range warnings {
+			fmt.Println(w)
+		}
+		if len(warnings) > 0 {
+			return 1
+		}
+		fmt.Println("no problems found")
+	case "print":
+		cfg := layers.resolve(fileName)
+		for _, w := range cfg.warnings {
+			fmt.Fprintln(os.Stderr, "warning:", w)
+		}
+		for _, line := range cfg.describe() {
+			fmt.Println(line)
+		}
+	case "defaults":
+		for _, line := range describeDefaults() {
+			fmt.Println(line)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown config command %q, expected check, print or defaults\n", args[0])
+		return 1
+	}
+	return 0
+}
@@ -37,7 +37,7 @@ func (c *Cursor) updateRenderedPos(view *View, content string, tabsize int) {
 // calculateRenderCol returns the position of the cursor on the rendered line
 func (c *Cursor) calculateRenderCol(content string, tabSize int, col int) int {
 	rCol := 0
-	for i, r := range content {
+	for i, r := range []rune(content) {
 		if i >= col {
 			break
 		}
@@ -0,0 +1,73 @@
+package main
+
+// MAX_DIFF_CELLS caps the size of the table used to diff two versions of
+// the lines; larger changes are a single replacement
+const MAX_DIFF_CELLS = 4_000_000
+
+// lineHunk replaces the old rows [start, end) with lines
+type lineHunk struct {
+	start, end int
+	lines      []string
+}
+
+// diffHunks finds the changes that turn before into after, as hunks in
+// document order. Lines are matched with a longest common subsequence so
+// that only the lines that actually changed are replaced.
+func diffHunks(before, after []string) []lineHunk {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+	a := before[prefix : len(before)-suffix]
+	b := after[prefix : len(after)-suffix]
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	if len(a)*len(b) > MAX_DIFF_CELLS {
+		return []lineHunk{{prefix, prefix + len(a), b}}
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var hunks []lineHunk
+	var current *lineHunk
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			current = nil
+			i++
+			j++
+			continue
+		}
+		if current == nil {
+			hunks = append(hunks, lineHunk{start: prefix + i, end: prefix + i})
+			current = &hunks[len(hunks)-1]
+		}
+		if j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]) {
+			current.end++
+			i++
+		} else {
+			current.lines = append(current.lines, b[j])
+			j++
+		}
+	}
+	return hunks
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffHunks(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []lineHunk
+	}{
+		{"equal", "a b c", "a b c", nil},
+		{"change one", "a b c", "a x c", []lineHunk{{1, 2, []string{"x"}}}},
+		{"insert", "a c", "a b c", []lineHunk{{1, 1, []string{"b"}}}},
+		{"delete", "a b c", "a c", []lineHunk{{1, 2, nil}}},
+		{"append", "a", "a b", []lineHunk{{1, 1, []string{"b"}}}},
+		{"two hunks", "a b c d e", "a x c d y e", []lineHunk{{1, 2, []string{"x"}}, {4, 4, []string{"y"}}}},
+		{"all new", "a b", "x y z", []lineHunk{{0, 2, []string{"x", "y", "z"}}}},
+		{"from empty", "", "a", []lineHunk{{0, 0, []string{"a"}}}},
+	}
+	for _, tt := range tests {
+		got := diffHunks(strings.Fields(tt.before), strings.Fields(tt.after))
+		if !slices.EqualFunc(got, tt.want, func(a, b lineHunk) bool {
+			return a.start == b.start && a.end == b.end && slices.Equal(a.lines, b.lines)
+		}) {
+			t.Errorf("%s: diffHunks = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// applying the hunks from the last one up turns before into after
+func TestDiffHunksApply(t *testing.T) {
+	tests := [][2]string{
+		{"a b c d e f", "a c d x f g"},
+		{"x a x b x", "a b"},
+		{"a b", "x a x b x"},
+		{"if { } else { }", "if { x } else { y }"},
+	}
+	for _, tt := range tests {
+		before, after := strings.Fields(tt[0]), strings.Fields(tt[1])
+		lines := slices.Clone(before)
+		hunks := diffHunks(before, after)
+		for i := len(hunks) - 1; i >= 0; i-- {
+			h := hunks[i]
+			lines = slices.Replace(lines, h.start, h.end, h.lines...)
+		}
+		if !slices.Equal(lines, after) {
+			t.Errorf("applying the hunks of %q to %q gave %q", tt[1], tt[0], lines)
+		}
+	}
+}
@@ -2,16 +2,22 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"slices"
 	"strings"
+	"unicode"
 )
 
+const UNTITLED = "[untitled]"
+
 const (
 	ErrRowOutOfBounds = gtextError("requested line number not in document")
 	ErrColOutOfBounds = gtextError("requested column position not in line")
+	ErrReadOnly       = gtextError("document is read-only")
 )
 
 type Document struct {
@@ -19,6 +25,18 @@ type Document struct {
 	lines    []line
 	dirty    bool
 	config   *Config
+	// readonly rejects every change to the lines
+	readonly bool
+	// bom is set when the file started with a byte order mark, which is
+	// kept on save
+	bom bool
+	// version counts changes to the lines
+	version int
+	// lexStates holds the lexer state at the start of the first rows,
+	// see lexStateAt
+	lexStates []lexState
+	// edit collects the rows changed while it is not nil, see forEachCursor
+	edit *editSpan
 }
 
 type line struct {
@@ -26,16 +44,36 @@ type line struct {
 	render  string
 }
 
-func NewDocument(fileName string, config *Config) *Document {
+// NewDocument creates an empty document with its own settings, resolved
+// from the config layers for fileName
+func NewDocument(fileName string, layers *ConfigLayers) *Document {
 	doc := Document{
 		fileName: fileName,
 		lines:    []line{{"", ""}},
 		dirty:    false,
-		config:   config,
+		config:   layers.resolve(fileName),
 	}
 	return &doc
 }
 
+// isUntitled reports whether the document has never been given a file name
+func (d *Document) isUntitled() bool {
+	return d.fileName == ""
+}
+
+// displayName returns the file name shown to the user
+func (d *Document) displayName() string {
+	if d.isUntitled() {
+		return UNTITLED
+	}
+	return d.fileName
+}
+
+// language returns the language detected from the file name
+func (d *Document) language() *Language {
+	return detectLanguage(d.fileName)
+}
+
 func (d *Document) lineCount() int {
 	return len(d.lines)
 }
@@ -99,36 +137,89 @@ func (d *Document) getLine(row int) (string, error) {
 
 // addLine adds a line at position row
 func (d *Document) addLine(row int, content string) error {
+	if d.readonly {
+		return ErrReadOnly
+	}
 	err := d.checkPosition(row, 0)
 	if err != nil {
 		return fmt.Errorf("could not add line at row %d: %w", row, err)
 	}
+	d.recordEdit(row, row, 1)
 	l := line{content: content, render: d.renderLine(content)}
 	d.lines = slices.Insert(d.lines, row, l)
+	d.touch(row)
 	return nil
 }
 
 // removeLine removes line at position row
 func (d *Document) removeLine(row int) error {
+	if d.readonly {
+		return ErrReadOnly
+	}
 	err := d.checkPosition(row, 0)
 	if err != nil {
 		return fmt.Errorf("could not add line at row %d: %w", row, err)
 	}
+	d.recordEdit(row, row+1, 0)
 	d.lines = slices.Delete(d.lines, row, row+1)
+	d.touch(row)
 	return nil
 }
 
 // replaceLine replaces the content and render of a line
 func (d *Document) replaceLine(row int, content string) error {
+	if d.readonly {
+		return ErrReadOnly
+	}
 	err := d.checkPosition(row, 0)
 	if err != nil {
 		return fmt.Errorf("could not delete line at row %d: %w", row, err)
 	}
+	d.recordEdit(row, row+1, 1)
 	l := line{content: content, render: d.renderLine(content)}
 	d.lines[row] = l
+	d.touch(row)
+	return nil
+}
+
+// replaceLines replaces rows [start, end) with contents as one change
+func (d *Document) replaceLines(start, end int, contents []string) error {
+	if d.readonly {
+		return ErrReadOnly
+	}
+	if start < 0 || end > d.lineCount() || start > end {
+		return fmt.Errorf("could not replace lines %d to %d: %w", start, end, ErrRowOutOfBounds)
+	}
+	newCount := len(contents)
+	if newCount == 0 && end-start == d.lineCount() {
+		newCount = 1 // the document keeps an empty line
+	}
+	d.recordEdit(start, end, newCount)
+	newLines := make([]line, 0, len(contents))
+	for _, content := range contents {
+		newLines = append(newLines, line{content: content, render: d.renderLine(content)})
+	}
+	d.lines = slices.Replace(d.lines, start, end, newLines...)
+	if len(d.lines) == 0 {
+		d.lines = []line{{"", ""}}
+	}
+	d.touch(start)
 	return nil
 }
 
+// touch records a change to the lines from row on
+func (d *Document) touch(row int) {
+	d.version++
+	d.lexStates = d.lexStates[:min(len(d.lexStates), row+1)]
+}
+
+// rerender recomputes the render of every line, e.g. after the tab size changed
+func (d *Document) rerender() {
+	for i := range d.lines {
+		d.lines[i].render = d.renderLine(d.lines[i].content)
+	}
+}
+
 // insertRune inserts a rune to the content of the line at the specified location
 // does not handle newline characters
 func (d *Document) insertRune(row, col int, r rune) error {
@@ -174,6 +265,59 @@ func (d *Document) deleteRune(row, col int) error {
 	return nil
 }
 
+// deleteRunes deletes the runes in columns [from, to) of a line
+// does not handle merging of rows
+func (d *Document) deleteRunes(row, from, to int) error {
+	err := d.checkPosition(row, from)
+	if err == nil {
+		err = d.checkPosition(row, to)
+	}
+	if err != nil || from > to {
+		return fmt.Errorf("could not delete runes at row %d, cols %d to %d: %w", row, from, to, ErrColOutOfBounds)
+	}
+
+	lineContent, err := d.getLine(row)
+	if err != nil {
+		return fmt.Errorf("could not get line at row %d: %w", row, err)
+	}
+	lineRunes := slices.Delete([]rune(lineContent), from, to)
+	err = d.replaceLine(row, string(lineRunes))
+	if err != nil {
+		return fmt.Errorf("could not replace line at row %d: %w", row, err)
+	}
+	return nil
+}
+
+// deleteForward deletes the rune at the specified location, joining the
+// next line when the location is at the end of a line; it reports
+// whether anything was deleted
+func (d *Document) deleteForward(row, col int) (bool, error) {
+	err := d.checkPosition(row, col)
+	if err == nil && row == d.lineCount() {
+		err = ErrRowOutOfBounds
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not delete forward at row %d, col %d: %w", row, col, err)
+	}
+
+	if col < d.getLineLength(row) {
+		err = d.deleteRunes(row, col, col+1)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if row == d.lineCount()-1 {
+		return false, nil
+	}
+	_, _, err = d.mergeLines(row + 1)
+	if err != nil {
+		return false, fmt.Errorf("could not join line %d with the next: %w", row, err)
+	}
+	return true, nil
+}
+
 // inserts a new empty line at the specified position
 func (d *Document) insertNewLine(row, col int) (newRow, newCol int, err error) {
 	err = d.checkPosition(row, col)
@@ -181,6 +325,10 @@ func (d *Document) insertNewLine(row, col int) (newRow, newCol int, err error) {
 		return 0, 0, fmt.Errorf("could not insert new line at row %d, col %d: %w", row, col, err)
 	}
 
+	if d.config.AutoIndent && col > 0 {
+		return d.insertIndentedNewLine(row, col)
+	}
+
 	if col == 0 {
 		err = d.addLine(row, "")
 		if err != nil {
@@ -218,6 +366,104 @@ func (d *Document) insertNewLine(row, col int) (newRow, newCol int, err error) {
 	return row + 1, 0, nil
 }
 
+// insertIndentedNewLine splits a line like insertNewLine, but starts the
+// new line at the indentation of the split line, one level deeper after
+// an opening bracket or, in languages such as Python, a trailing colon, and
+// trims the whitespace left at the end of the split line
+func (d *Document) insertIndentedNewLine(row, col int) (newRow, newCol int, err error) {
+	lineContent, err := d.getLine(row)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get line at row %d: %w", row, err)
+	}
+
+	lineRunes := []rune(lineContent)
+	indent := leadingWhitespace(lineContent)
+	before := strings.TrimRightFunc(string(lineRunes[:col]), unicode.IsSpace)
+	after := strings.TrimLeftFunc(string(lineRunes[col:]), unicode.IsSpace)
+
+	newIndent := indent
+	newLines := []string{newIndent + after}
+	if opener, ok := lastRune(before); ok && (isOpener(opener) || opener == ':' && d.language().colonIndents) {
+		newIndent += d.config.indentUnit()
+		newLines = []string{newIndent + after}
+		// the cursor was between a pair of brackets, put the closer on its own line
+		if closer, ok := firstRune(after); ok && isOpener(opener) && closer == closerFor(opener) {
+			newLines = []string{newIndent, indent + after}
+		}
+	}
+
+	err = d.replaceLine(row, before)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not replace line at current row %d: %w", row, err)
+	}
+	for i, content := range newLines {
+		err = d.addLine(row+1+i, content)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not add line at row %d: %w", row+1+i, err)
+		}
+	}
+	return row + 1, len([]rune(newIndent)), nil
+}
+
+// dedentLine removes one level of indentation from the start of a line,
+// returning the number of runes removed
+func (d *Document) dedentLine(row int) (int, error) {
+	lineContent, err := d.getLine(row)
+	if err != nil {
+		return 0, fmt.Errorf("could not dedent line at row %d: %w", row, err)
+	}
+	removed := 0
+	if strings.HasPrefix(lineContent, "\t") {
+		removed = 1
+	} else {
+		for removed < d.config.TabSize && removed < len(lineContent) && lineContent[removed] == ' ' {
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	err = d.replaceLine(row, lineContent[removed:])
+	if err != nil {
+		return 0, fmt.Errorf("could not dedent line at row %d: %w", row, err)
+	}
+	return removed, nil
+}
+
+// indentLines adds one level of indentation to rows [start, end],
+// leaving blank lines untouched
+func (d *Document) indentLines(start, end int) error {
+	if start < 0 || end >= d.lineCount() || start > end {
+		return fmt.Errorf("could not indent lines %d to %d: %w", start, end, ErrRowOutOfBounds)
+	}
+	unit := d.config.indentUnit()
+	for row := start; row <= end; row++ {
+		content := d.lines[row].content
+		if isBlank(content) {
+			continue
+		}
+		err := d.replaceLine(row, unit+content)
+		if err != nil {
+			return fmt.Errorf("could not indent line at row %d: %w", row, err)
+		}
+	}
+	return nil
+}
+
+// dedentLines removes one level of indentation from rows [start, end]
+func (d *Document) dedentLines(start, end int) error {
+	if start < 0 || end >= d.lineCount() || start > end {
+		return fmt.Errorf("could not dedent lines %d to %d: %w", start, end, ErrRowOutOfBounds)
+	}
+	for row := start; row <= end; row++ {
+		_, err := d.dedentLine(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // mergeLines handles deleting the newline character at the beginning of a line
 func (d *Document) mergeLines(row int) (newRow, newCol int, err error) {
 	err = d.checkPosition(row, 0)
@@ -252,7 +498,10 @@ func (d *Document) Load(r io.Reader) error {
 	scanner := bufio.NewScanner(r)
 	var lines []line
 	for scanner.Scan() {
-		content := scanner.Text()
+		content := decodeLine(scanner.Bytes(), d.config.Charset)
+		if len(lines) == 0 && d.config.Charset != "latin1" {
+			content, d.bom = strings.CutPrefix(content, BOM)
+		}
 		lines = append(lines, line{content: content, render: d.renderLine(content)})
 	}
 	if err := scanner.Err(); err != nil {
@@ -263,12 +512,20 @@ func (d *Document) Load(r io.Reader) error {
 	} else {
 		d.lines = lines
 	}
+	d.touch(0)
 	return nil
 }
 
-// LoadFromDisk reads the file content from the filename
+// LoadFromDisk reads the file content from the filename,
+// a file that does not exist yet is left empty and only created on save
 func (d *Document) LoadFromDisk() error {
-	file, err := os.OpenFile(d.fileName, os.O_RDONLY|os.O_CREATE, 0644)
+	if d.isUntitled() {
+		return nil
+	}
+	file, err := os.Open(d.fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("error opening file %s from disk: %w", d.fileName, err)
 	}
@@ -284,11 +541,21 @@ func (d *Document) LoadFromDisk() error {
 // Save writes the contents of the document into the writer
 func (d *Document) Save(w io.Writer) (int, error) {
 	var builder strings.Builder
-	for _, line := range d.lines {
+	if d.config.Charset == "utf-8-bom" || d.bom && d.config.Charset != "latin1" {
+		builder.WriteString(BOM)
+	}
+	ending := d.config.lineEnding()
+	for i, line := range d.lines {
 		builder.WriteString(line.content)
-		builder.WriteRune('\n')
+		if i < len(d.lines)-1 || d.config.InsertFinalNewline {
+			builder.WriteString(ending)
+		}
+	}
+	data, err := encodeText(builder.String(), d.config.Charset)
+	if err != nil {
+		return 0, fmt.Errorf("error writing file: %w", err)
 	}
-	n, err := w.Write([]byte(builder.String()))
+	n, err := w.Write(data)
 	if err != nil {
 		return 0, fmt.Errorf("error writing file: %w", err)
 	}
@@ -297,9 +564,14 @@ func (d *Document) Save(w io.Writer) (int, error) {
 
 // SaveToDisk writes the contents to the document's filename
 func (d *Document) SaveToDisk() (int, error) {
-	file, err := os.OpenFile(d.fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	return d.WriteToDisk(d.fileName)
+}
+
+// WriteToDisk writes the contents to path, leaving the document's filename unchanged
+func (d *Document) WriteToDisk(path string) (int, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		return 0, fmt.Errorf("error opening file %s from disk: %w", d.fileName, err)
+		return 0, fmt.Errorf("error opening file %s from disk: %w", path, err)
 	}
 	defer file.Close()
 
@@ -308,7 +580,7 @@ func (d *Document) SaveToDisk() (int, error) {
 
 	n, err := d.Save(writer)
 	if err != nil {
-		return 0, fmt.Errorf("failed to write content of file %s: %w", d.fileName, err)
+		return 0, fmt.Errorf("failed to write content of file %s: %w", path, err)
 	}
 	return n, nil
 }
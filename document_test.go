@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTestDocument(t *testing.T, fileName, text string) *Document {
+	t.Helper()
+	doc := NewDocument(fileName, &ConfigLayers{})
+	err := doc.Load(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// newTestEditor returns an editor for a document with the cursor at the
+// start, without a terminal
+func newTestEditor(t *testing.T, fileName, text string) *Editor {
+	t.Helper()
+	doc := newTestDocument(t, fileName, text)
+	return &Editor{
+		view:     NewView(24, 80, doc.config),
+		cursor:   NewCursor(0, 0),
+		document: doc,
+		layers:   &ConfigLayers{},
+		commands: &CommandRegistry{},
+	}
+}
+
+func docLines(d *Document) []string {
+	lines := make([]string, d.lineCount())
+	for i, l := range d.lines {
+		lines[i] = l.content
+	}
+	return lines
+}
+
+func TestDeleteForward(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		row, col int
+		want     []string
+		changed  bool
+		err      error
+	}{
+		{"middle of a line", "abc\ndef", 0, 1, []string{"ac", "def"}, true, nil},
+		{"multi-byte rune", "héllo", 0, 1, []string{"hllo"}, true, nil},
+		{"end of a line joins the next", "abc\ndef", 0, 3, []string{"abcdef"}, true, nil},
+		{"empty line joins the next", "\ndef", 0, 0, []string{"def"}, true, nil},
+		{"end of the document", "abc\ndef", 1, 3, []string{"abc", "def"}, false, nil},
+		{"column past the end", "abc", 0, 4, []string{"abc"}, false, ErrColOutOfBounds},
+		{"row past the end", "abc", 1, 0, []string{"abc"}, false, ErrRowOutOfBounds},
+	}
+	for _, tt := range tests {
+		doc := newTestDocument(t, "", tt.text)
+		changed, err := doc.deleteForward(tt.row, tt.col)
+		if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+		if changed != tt.changed || !slices.Equal(docLines(doc), tt.want) {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.name, docLines(doc), changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestInsertIndentedNewLine(t *testing.T) {
+	unit := DefaultConfig().indentUnit()
+	tests := []struct {
+		name, file string
+		line       string
+		col        int
+		want       []string
+		cursor     position
+	}{
+		{"keeps the indentation", "a.c", "  foo();", 8, []string{"  foo();", "  "}, position{1, 2}},
+		{"splits the line", "a.c", "  foo bar", 5, []string{"  foo", "  bar"}, position{1, 2}},
+		{"trims around the split", "a.c", "foo   bar", 4, []string{"foo", "bar"}, position{1, 0}},
+		{"indents after an opener", "a.c", "if (x) {", 8, []string{"if (x) {", unit}, position{1, len(unit)}},
+		{"puts the closer on its own line", "a.c", "\tf(){}", 5, []string{"\tf(){", "\t" + unit, "\t}"}, position{1, 1 + len(unit)}},
+		{"colon in python", "a.py", "def f():", 8, []string{"def f():", unit}, position{1, len(unit)}},
+		{"colon in yaml", "a.yaml", "key:", 4, []string{"key:", unit}, position{1, len(unit)}},
+		{"colon elsewhere", "a.c", "label:", 6, []string{"label:", ""}, position{1, 0}},
+	}
+	for _, tt := range tests {
+		doc := newTestDocument(t, tt.file, tt.line)
+		row, col, err := doc.insertIndentedNewLine(0, tt.col)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := docLines(doc); !slices.Equal(got, tt.want) || (position{row, col}) != tt.cursor {
+			t.Errorf("%s: got %q at %v, want %q at %v", tt.name, got, position{row, col}, tt.want, tt.cursor)
+		}
+	}
+}
+
+func TestOpenerBefore(t *testing.T) {
+	doc := newTestDocument(t, "a.c", "f(a, {\n  [b],\n  (c)\n  ")
+	tests := []struct {
+		pos    position
+		closer rune
+		want   position
+		ok     bool
+	}{
+		{position{3, 2}, '}', position{0, 5}, true},
+		{position{3, 2}, ')', position{0, 1}, true},
+		{position{2, 2}, ')', position{0, 1}, true},
+		{position{2, 3}, ')', position{2, 2}, true},
+		{position{3, 2}, ']', position{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := doc.openerBefore(tt.pos, tt.closer)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("openerBefore(%v, %q) = %v, %v, want %v, %v", tt.pos, tt.closer, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIndentLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		expandTabs bool
+		text       string
+		start, end int
+		want       []string
+	}{
+		{"tabs", false, "a\n\tb\nc", 0, 1, []string{"\ta", "\t\tb", "c"}},
+		{"spaces", true, "a\n  b", 0, 1, []string{"    a", "      b"}},
+		{"skips blank lines", true, "a\n  \nb", 0, 2, []string{"    a", "  ", "    b"}},
+	}
+	for _, tt := range tests {
+		doc := newTestDocument(t, "", tt.text)
+		doc.config.ExpandTabs, doc.config.TabSize = tt.expandTabs, 4
+		err := doc.indentLines(tt.start, tt.end)
+		if err != nil || !slices.Equal(docLines(doc), tt.want) {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, docLines(doc), err, tt.want)
+		}
+	}
+
+	doc := newTestDocument(t, "", "a\nb")
+	if err := doc.indentLines(1, 2); !errors.Is(err, ErrRowOutOfBounds) {
+		t.Errorf("indentLines past the end: %v, want %v", err, ErrRowOutOfBounds)
+	}
+}
+
+func TestDedentLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		start, end int
+		want       []string
+	}{
+		{"tabs", "\ta\n\t\tb\nc", 0, 2, []string{"a", "\tb", "c"}},
+		{"one level of spaces", "      a\n    b", 0, 1, []string{"  a", "b"}},
+		{"less than a level", "  a\nb", 0, 0, []string{"a", "b"}},
+		{"tab before spaces", "\t  a", 0, 0, []string{"  a"}},
+		{"spaces before a tab", "  \ta", 0, 0, []string{"\ta"}},
+		{"only the range", "\ta\n\tb", 1, 1, []string{"\ta", "b"}},
+	}
+	for _, tt := range tests {
+		doc := newTestDocument(t, "", tt.text)
+		doc.config.TabSize = 4
+		err := doc.dedentLines(tt.start, tt.end)
+		if err != nil || !slices.Equal(docLines(doc), tt.want) {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, docLines(doc), err, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 	"unicode"
@@ -24,17 +25,33 @@ type Editor struct {
 	document     *Document
 	view         *View
 	cursor       *Cursor
+	extraCursors []*Cursor
+	lastCursor   position // the most recently added extra cursor
 	finder       *Finder
-	config       *Config
+	palette      *Palette
+	prompt       *Prompt
+	histories    map[string]*History
+	jumps        *JumpList
+	mark         *position
+	block        *position // anchor of the block selection
+	brackets     bracketCache
+	layers       *ConfigLayers
+	readonly     bool // open every document read-only
 	inputChan    chan KeyEvent
 	mode         EditorMode
 	status       string
-	buffer       []string
+	message      []string // output shown above the footer until the next key
+	killRing     *KillRing
+	yanked       lineRange
+	macros       *Macros
+	job          *shellJob
+	jobChan      chan shellResult
 	commands     *CommandRegistry
 	quitChan     chan struct{}
 	exiting      bool
 	exitCode     int
-	clearBuffer  bool
+	lastCommand  string
+	thisCommand  string
 	shutdownOnce sync.Once
 }
 
@@ -43,6 +60,8 @@ type EditorMode byte
 const (
 	EditMode EditorMode = iota
 	FindMode
+	PaletteMode
+	PromptMode
 )
 
 type KeyEvent struct {
@@ -50,138 +69,471 @@ type KeyEvent struct {
 	err error
 }
 
-func NewEditor(r *os.File, fileName string) *Editor {
-	cfg := loadConfig()
+func NewEditor(r *os.File, fileName string, layers *ConfigLayers, readonly bool) *Editor {
+	doc := NewDocument(fileName, layers)
+	doc.readonly = readonly
 	e := &Editor{
-		reader:      bufio.NewReader(r),
-		view:        NewView(1, 1, cfg),
-		cursor:      NewCursor(0, 0),
-		finder:      &Finder{},
-		inputChan:   make(chan KeyEvent, 32),
-		document:    NewDocument(fileName, cfg),
-		config:      cfg,
-		mode:        EditMode,
-		status:      "Edit Mode",
-		buffer:      make([]string, 0),
-		commands:    &CommandRegistry{},
-		exiting:     false,
-		quitChan:    make(chan struct{}),
-		exitCode:    0,
-		clearBuffer: false,
+		reader:    bufio.NewReader(r),
+		view:      NewView(1, 1, doc.config),
+		cursor:    NewCursor(0, 0),
+		finder:    &Finder{},
+		palette:   &Palette{},
+		histories: make(map[string]*History),
+		jumps:     &JumpList{},
+		inputChan: make(chan KeyEvent, 32),
+		jobChan:   make(chan shellResult, 1),
+		message:   doc.config.warnings,
+		document:  doc,
+		layers:    layers,
+		readonly:  readonly,
+		mode:      EditMode,
+		status:    "Edit Mode",
+		killRing:  NewKillRing(doc.config.KillRingSize),
+		macros:    NewMacros(macrosPath()),
+		commands:  &CommandRegistry{},
+		exiting:   false,
+		quitChan:  make(chan struct{}),
+		exitCode:  0,
 	}
+	e.commands.onError = e.reportError
 	e.registerCommands()
+	e.loadMacros()
 	return e
 }
 
 func (e *Editor) registerCommands() {
 	e.commands.register(Command{
-		key:    CTRL_Q,
-		name:   "Ctrl-Q",
-		desc:   "Quit",
-		action: e.handleQuit,
+		name:    "quit",
+		aliases: []string{"q"},
+		key:     CTRL_Q,
+		desc:    "Quit",
+		action:  e.handleQuit,
 	})
 
 	e.commands.register(Command{
+		name:   "save",
 		key:    CTRL_S,
-		name:   "Ctrl-S",
 		desc:   "Save file",
 		action: e.handleSave,
 	})
 
 	e.commands.register(Command{
+		name:   "open",
+		key:    CTRL_O,
+		desc:   "Open file",
+		action: e.handleOpen,
+	})
+
+	e.commands.register(Command{
+		name:   "find",
 		key:    CTRL_F,
-		name:   "Ctrl-F",
 		desc:   "Find mode",
 		action: e.handleFind,
 	})
 
 	e.commands.register(Command{
+		name:   "goto-prompt",
+		key:    CTRL_G,
+		desc:   "Go to line",
+		action: e.handleGoto,
+	})
+
+	e.commands.register(Command{
+		name:   "jump-back",
+		key:    ALT | ',',
+		desc:   "Jump back",
+		action: e.handleJumpBack,
+	})
+
+	e.commands.register(Command{
+		name:   "jump-forward",
+		key:    ALT | '.',
+		desc:   "Jump forward",
+		action: e.handleJumpForward,
+	})
+
+	e.commands.register(Command{
+		name:   "match-bracket",
+		key:    CTRL_CLOSE_BRACKET,
+		desc:   "Jump to matching bracket",
+		action: e.handleMatchBracket,
+	})
+
+	e.commands.register(Command{
+		name:   "mark-lines",
+		key:    CTRL_B,
+		desc:   "Mark lines",
+		action: e.handleMark,
+	})
+
+	e.commands.register(Command{
+		name:   "indent-lines",
+		desc:   "Indent line or marked lines",
+		action: e.handleIndentLines,
+	})
+
+	e.commands.register(Command{
+		name:   "dedent-lines",
+		key:    SHIFT | TAB,
+		desc:   "Dedent line or marked lines",
+		action: e.handleDedentLines,
+	})
+
+	e.commands.register(Command{
+		name:   "toggle-comment",
+		key:    CTRL_SLASH,
+		desc:   "Toggle comment on line or marked lines",
+		action: e.handleToggleComment,
+	})
+
+	e.commands.register(Command{
+		name:   "move-lines-up",
+		key:    ALT | ARROW_UP,
+		desc:   "Move line or marked lines up",
+		action: e.handleMoveLinesUp,
+	})
+
+	e.commands.register(Command{
+		name:   "move-lines-down",
+		key:    ALT | ARROW_DOWN,
+		desc:   "Move line or marked lines down",
+		action: e.handleMoveLinesDown,
+	})
+
+	e.commands.register(Command{
+		name:   "duplicate-lines",
+		key:    CTRL_D,
+		desc:   "Duplicate line or marked lines",
+		action: e.handleDuplicateLines,
+	})
+
+	e.commands.register(Command{
+		name:   "join-lines",
+		key:    ALT | 'j',
+		desc:   "Join next line or marked lines",
+		action: e.handleJoinLines,
+	})
+
+	e.commands.register(Command{
+		name:   "cut-line",
 		key:    CTRL_X,
-		name:   "Ctrl-X",
-		desc:   "Cut line",
+		desc:   "Cut line or marked lines",
 		action: e.handleCut,
 	})
 
 	e.commands.register(Command{
+		name:   "copy-line",
 		key:    CTRL_C,
-		name:   "Ctrl-C",
-		desc:   "Copy line",
+		desc:   "Copy line or marked lines",
 		action: e.handleCopy,
 	})
 
 	e.commands.register(Command{
-		key:    CTRL_V,
-		name:   "Ctrl-V",
-		desc:   "Paste line",
-		action: e.handlePaste,
+		name:    "yank",
+		aliases: []string{"paste"},
+		key:     CTRL_V,
+		desc:    "Paste newest cut",
+		action:  e.handleYank,
+	})
+
+	e.commands.register(Command{
+		name:   "yank-pop",
+		key:    ALT | 'y',
+		desc:   "Replace paste with previous cut",
+		action: e.handleYankPop,
+	})
+
+	e.commands.register(Command{
+		name:   "block-select",
+		key:    ALT | 'r',
+		desc:   "Start / clear block selection",
+		action: e.handleBlockSelect,
+	})
+
+	e.commands.register(Command{
+		name:   "paste-block",
+		key:    ALT | 'v',
+		desc:   "Paste newest cut as a block",
+		action: e.handlePasteBlock,
+	})
+
+	e.commands.register(Command{
+		name:   "add-cursor-above",
+		key:    CTRL | ALT | ARROW_UP,
+		desc:   "Add cursor above",
+		action: e.handleAddCursorAbove,
+	})
+
+	e.commands.register(Command{
+		name:   "add-cursor-below",
+		key:    CTRL | ALT | ARROW_DOWN,
+		desc:   "Add cursor below",
+		action: e.handleAddCursorBelow,
+	})
+
+	e.commands.register(Command{
+		name:   "add-cursor-next-match",
+		key:    CTRL_N,
+		desc:   "Add cursor at next match",
+		action: e.handleAddCursorAtNextMatch,
+	})
+
+	e.commands.register(Command{
+		name:   "add-cursors-all-matches",
+		key:    ALT | 'n',
+		desc:   "Add cursors at all matches",
+		action: e.handleAddCursorsAtAllMatches,
+	})
+
+	e.commands.register(Command{
+		name:   "record-macro",
+		key:    ALT | 'm',
+		desc:   "Start / stop recording a macro",
+		action: e.handleRecordMacro,
+	})
+
+	e.commands.register(Command{
+		name:   "play-macro",
+		key:    ALT | 'p',
+		desc:   "Play the last recorded macro",
+		action: e.handlePlayMacro,
+	})
+
+	e.commands.register(Command{
+		name:   "command-palette",
+		key:    CTRL_P,
+		desc:   "Commands",
+		action: e.handlePalette,
+	})
+
+	e.commands.register(Command{
+		name:   "command-line",
+		key:    CTRL_E,
+		desc:   "Command line",
+		action: e.handleCommandLine,
 	})
+
+	e.registerExCommands()
 }
 
 func (e *Editor) handleSave() {
-	n, err := e.document.SaveToDisk()
+	if e.document.readonly {
+		e.reportError(ErrReadOnly)
+		return
+	}
+	if e.document.isUntitled() {
+		e.handleSaveAs()
+		return
+	}
+	path := e.document.fileName
+	e.prepareSave(path, e.document.config, func() {
+		e.writeDocument(path)
+	})
+}
+
+// writeDocument writes the document to path as it is and marks it saved
+func (e *Editor) writeDocument(path string) error {
+	n, err := e.document.WriteToDisk(path)
 	if err != nil {
 		e.setStatus(fmt.Sprintf("Error saving: %v", err), 2)
-	} else {
-		e.setStatus(fmt.Sprintf("Wrote %d bytes", n), 2)
-		e.document.dirty = false
+		return err
 	}
+	e.setStatus(fmt.Sprintf("Wrote %d bytes", n), 2)
+	e.document.dirty = false
+	return nil
 }
 
-func (e *Editor) handleCut() {
-	if e.clearBuffer {
-		e.buffer = e.buffer[:0]
-		e.clearBuffer = false
+func (e *Editor) handleSaveAs() {
+	e.promptFileName("Save as: ", e.document.fileName, e.saveAs)
+}
+
+// saveAs saves the document under a new name, confirming before
+// overwriting another existing file
+func (e *Editor) saveAs(path string) {
+	path = expandPath(strings.TrimSpace(path))
+	if path == "" {
+		e.reportError(ErrEmptyFileName)
+		return
 	}
-	currentRow := e.cursor.row
-	if currentRow == e.document.lineCount()-1 {
-		e.moveUp()
+	if e.document.readonly {
+		e.reportError(ErrReadOnly)
+		return
 	}
-	content, err := e.document.getLine(currentRow)
-	if e.handleError("could not copy current line", err) {
+	save := func() {
+		// the file is normalized, formatted and written with the settings of
+		// its new name, which the document only takes once the write succeeded
+		cfg := e.layers.resolve(path)
+		e.prepareSave(path, cfg, func() {
+			previous := e.document.config
+			e.document.config = cfg
+			if e.writeDocument(path) != nil {
+				e.document.config = previous
+				return
+			}
+			e.document.fileName = path
+			e.applyConfig()
+			e.message = e.document.config.warnings
+			// the new name may have another language and lexer
+			e.document.touch(0)
+		})
+	}
+	if _, err := os.Stat(path); err == nil && path != e.document.fileName {
+		e.confirm(fmt.Sprintf("%s exists, overwrite?", path), save)
 		return
 	}
-	err = e.document.removeLine(currentRow)
-	if e.handleError("could not remove current line", err) {
+	save()
+}
+
+func (e *Editor) handleOpen() {
+	e.promptFileName("Open: ", "", e.open)
+}
+
+// open replaces the current document, confirming before discarding changes
+func (e *Editor) open(path string) {
+	path = expandPath(strings.TrimSpace(path))
+	switchFile := func() {
+		origin := e.currentJump()
+		err := e.openFile(path)
+		if err != nil {
+			e.reportError(err)
+			return
+		}
+		e.jumps.record(origin)
+	}
+	if e.document.dirty {
+		e.confirm("Unsaved changes, discard them?", func() {
+			e.document.dirty = false
+			switchFile()
+		})
 		return
 	}
-	e.setDirty()
-	e.buffer = append(e.buffer, content)
-	e.setStatus("cut line", 1)
+	switchFile()
 }
 
-func (e *Editor) handleCopy() {
-	if e.clearBuffer {
-		e.buffer = e.buffer[:0]
-		e.clearBuffer = false
+// openFile replaces the current document with the file at path
+func (e *Editor) openFile(path string) error {
+	if path == "" {
+		return ErrEmptyFileName
 	}
-	currentRow := e.cursor.row
-	content, err := e.document.getLine(currentRow)
-	if content == "" {
+	if e.document.dirty {
+		return ErrUnsavedChanges
+	}
+	doc := NewDocument(path, e.layers)
+	doc.readonly = e.readonly
+	err := doc.LoadFromDisk()
+	if err != nil {
+		return err
+	}
+	e.document = doc
+	e.applyConfig()
+	e.message = doc.config.warnings
+	e.cursor.moveTo(0, 0)
+	e.cursor.anchor = 0
+	e.view.rowOffset = 0
+	e.finder.reset()
+	e.setStatus(fmt.Sprintf("Opened %s", path), 2)
+	return nil
+}
+
+// handleCut removes the line or marked lines into the kill ring
+func (e *Editor) handleCut() {
+	if e.block != nil {
+		e.cutBlock()
 		return
 	}
-	if e.handleError("could not copy current line", err) {
+	r := e.selectedLines()
+	var lines []string
+	for row := r.start; row <= r.end; row++ {
+		content, err := e.document.getLine(row)
+		if e.handleError("could not copy current line", err) {
+			return
+		}
+		lines = append(lines, content)
+	}
+	replacement := []string{}
+	if r.count() == e.document.lineCount() {
+		// keep a single empty line rather than an empty document
+		replacement = []string{""}
+	}
+	err := e.document.replaceLines(r.start, r.end+1, replacement)
+	if e.handleError("could not remove current line", err) {
 		return
 	}
-	e.buffer = append(e.buffer, content)
-	e.setStatus("copied line", 1)
+	e.setDirty()
+	e.clearMark()
+	e.cursor.moveTo(min(r.start, e.document.lineCount()-1), 0)
+	e.cursor.anchor = 0
+	e.kill(lines)
+	e.setStatus(fmt.Sprintf("cut %d lines", len(lines)), 1)
 }
 
-func (e *Editor) handlePaste() {
-	bufferLen := len(e.buffer)
-	if bufferLen == 0 {
+// handleCopy copies the line or marked lines into the kill ring
+func (e *Editor) handleCopy() {
+	if e.block != nil {
+		e.copyBlock()
 		return
 	}
-	currentRow := e.cursor.row
-	for idx, content := range e.buffer {
-		err := e.document.addLine(currentRow+idx, content)
-		if e.handleError("could not insert line", err) {
+	r := e.selectedLines()
+	var lines []string
+	for row := r.start; row <= r.end; row++ {
+		content, err := e.document.getLine(row)
+		if e.handleError("could not copy current line", err) {
 			return
 		}
-		e.moveDown()
-		e.setDirty()
+		lines = append(lines, content)
+	}
+	e.clearMark()
+	e.kill(lines)
+	e.setStatus(fmt.Sprintf("copied %d lines", len(lines)), 1)
+}
+
+// kill stores lines in the kill ring; consecutive cuts and copies are
+// collected into a single entry
+func (e *Editor) kill(lines []string) {
+	if e.lastCommand == "kill" {
+		e.killRing.appendToNewest(lines)
+	} else {
+		e.killRing.push(lines)
+	}
+	e.thisCommand = "kill"
+}
+
+// handleYank pastes the newest kill ring entry above the cursor line
+func (e *Editor) handleYank() {
+	lines, ok := e.killRing.newest()
+	if !ok {
+		e.setStatus("kill ring is empty", 1)
+		return
+	}
+	e.yankLines(lines, e.cursor.row, e.cursor.row)
+}
+
+// handleYankPop replaces the lines just pasted with the previous entry
+func (e *Editor) handleYankPop() {
+	if e.lastCommand != "yank" {
+		e.setStatus("previous command was not a paste", 1)
+		return
+	}
+	lines, ok := e.killRing.previous()
+	if !ok {
+		return
 	}
-	e.setStatus(fmt.Sprintf("pasted %d lines", bufferLen), 1)
-	e.clearBuffer = true
+	e.yankLines(lines, e.yanked.start, e.yanked.end+1)
+}
+
+// yankLines replaces rows [start, end) with lines and leaves the cursor
+// below them, remembering where they went for a following yank-pop
+func (e *Editor) yankLines(lines []string, start, end int) {
+	err := e.document.replaceLines(start, end, lines)
+	if e.handleError("could not insert line", err) {
+		return
+	}
+	e.setDirty()
+	e.yanked = lineRange{start, start + len(lines) - 1}
+	row := min(start+len(lines), e.document.lineCount()-1)
+	e.cursor.moveTo(row, min(e.cursor.col, e.document.getLineLength(row)))
+	e.thisCommand = "yank"
+	e.setStatus(fmt.Sprintf("pasted %d lines [%d/%d]", len(lines), e.killRing.yankIdx+1, e.killRing.len()), 1)
 }
 
 func (e *Editor) handleFind() {
@@ -198,6 +550,25 @@ func (e *Editor) handleFind() {
 	}
 }
 
+func (e *Editor) handlePalette() {
+	switch e.mode {
+	case PaletteMode:
+		e.closePalette()
+	default:
+		if e.mode == FindMode {
+			e.finder.reset()
+		}
+		e.mode = PaletteMode
+		e.palette.reset()
+		e.palette.filter(e.commands.all())
+	}
+}
+
+func (e *Editor) closePalette() {
+	e.mode = EditMode
+	e.palette.reset()
+}
+
 func (e *Editor) handleQuit() {
 	if e.exiting {
 		e.requestShutdown(0)
@@ -224,6 +595,16 @@ func (e *Editor) requestShutdown(code int) {
 	})
 }
 
+// shuttingDown reports whether requestShutdown was called
+func (e *Editor) shuttingDown() bool {
+	select {
+	case <-e.quitChan:
+		return true
+	default:
+		return false
+	}
+}
+
 func (e *Editor) readInputStream() {
 	for {
 		r, err := ReadKey(e.reader)
@@ -303,16 +684,122 @@ func (e *Editor) moveCursor(r rune) {
 		e.cursor.col = 0
 	case END:
 		e.cursor.col = e.currentLineLength()
+	case CTRL | ARROW_LEFT, ALT | ARROW_LEFT, ALT | 'b':
+		e.moveWordLeft()
+	case CTRL | ARROW_RIGHT, ALT | ARROW_RIGHT, ALT | 'f':
+		e.moveWordRight()
+	case CTRL | ARROW_UP:
+		e.moveParagraphUp()
+	case CTRL | ARROW_DOWN:
+		e.moveParagraphDown()
 	}
 }
 
 func (e *Editor) processKeyPress(r rune) {
+	if r == 0 {
+		// unknown control sequences read as no key at all
+		return
+	}
+	e.macros.capture(r, e.mode == EditMode)
 	e.clearStatus()
+	e.message = nil
+	if e.job != nil {
+		e.handleJobKey(r)
+		return
+	}
+	e.lastCommand, e.thisCommand = e.thisCommand, ""
 	switch e.mode {
 	case EditMode:
 		e.handleEditModeKey(r)
 	case FindMode:
 		e.handleFindModeKey(r)
+	case PaletteMode:
+		e.handlePaletteModeKey(r)
+	case PromptMode:
+		e.handlePromptModeKey(r)
+	}
+}
+
+// openPrompt switches to prompt mode; kind selects which history is used
+func (e *Editor) openPrompt(label, initial, kind string, complete func(string) []string, submit func(string)) {
+	if e.mode == FindMode {
+		e.finder.reset()
+	}
+	e.prompt = NewPrompt(label, initial, e.historyFor(kind), complete, submit)
+	e.mode = PromptMode
+}
+
+// promptFileName asks for a file name, completing paths from disk
+func (e *Editor) promptFileName(label, initial string, submit func(string)) {
+	e.openPrompt(label, initial, "file", completePath, submit)
+}
+
+// confirm asks a yes/no question and runs onYes if the answer is yes
+func (e *Editor) confirm(question string, onYes func()) {
+	e.openPrompt(question+" (y/n) ", "", "", nil, func(answer string) {
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			onYes()
+		default:
+			e.setStatus("Cancelled", 2)
+		}
+	})
+}
+
+func (e *Editor) closePrompt() {
+	e.mode = EditMode
+	e.prompt = nil
+}
+
+func (e *Editor) historyFor(kind string) *History {
+	if kind == "" {
+		return nil
+	}
+	h, ok := e.histories[kind]
+	if !ok {
+		h = &History{}
+		e.histories[kind] = h
+	}
+	return h
+}
+
+func (e *Editor) handlePromptModeKey(r rune) {
+	switch r {
+	case ESCAPE:
+		e.closePrompt()
+	case RETURN:
+		p := e.prompt
+		e.closePrompt()
+		if p.history != nil {
+			p.history.add(p.text())
+		}
+		p.submit(p.text())
+	default:
+		e.prompt.edit(r)
+	}
+}
+
+func (e *Editor) handlePaletteModeKey(r rune) {
+	switch r {
+	case ESCAPE, CTRL_P:
+		e.closePalette()
+	case ARROW_UP:
+		e.palette.moveSelection(-1)
+	case ARROW_DOWN:
+		e.palette.moveSelection(1)
+	case RETURN:
+		cmd, ok := e.palette.current()
+		e.closePalette()
+		if !ok {
+			return
+		}
+		if cmd.requiresArgs() {
+			e.openCommandLine(cmd.name + " ")
+			return
+		}
+		e.commands.report(cmd.call(nil))
+	default:
+		e.palette.editQuery(r, e.commands.all())
 	}
 }
 
@@ -337,8 +824,8 @@ func (e *Editor) findMatches() {
 	e.finder.find(e.document)
 	pos := e.finder.first()
 	if pos.row != -1 || pos.col != -1 {
+		e.recordJump()
 		e.cursor.moveTo(pos.row, pos.col)
-
 	}
 }
 
@@ -360,12 +847,58 @@ func (e *Editor) handleEditModeKey(r rune) {
 	if e.commands.execute(r) {
 		return
 	}
+	if e.document.readonly && isEditKey(r) {
+		e.reportError(ErrReadOnly)
+		return
+	}
+	if e.block != nil && e.handleBlockKey(r) {
+		return
+	}
+	switch r {
+	case TAB:
+		if _, ok := e.markedLines(); ok {
+			e.handleIndentLines()
+			return
+		}
+	case ESCAPE:
+		e.clearMark()
+		e.clearExtraCursors()
+		return
+	}
+	if e.hasExtraCursors() {
+		e.forEachCursor(func() { e.handleCursorKey(r) })
+		return
+	}
+	e.handleCursorKey(r)
+}
+
+// isEditKey reports whether a key that is not bound to a command changes
+// the text
+func isEditKey(r rune) bool {
 	switch r {
-	case ARROW_UP, ARROW_DOWN, ARROW_RIGHT, ARROW_LEFT, PAGE_UP, PAGE_DOWN, HOME, END:
+	case DELETE, BACKSPACE, DEL_KEY, CTRL | DELETE, ALT | DELETE, CTRL | DEL_KEY, ALT | 'd', RETURN, TAB:
+		return true
+	}
+	return unicode.IsPrint(r) || r == SPACE
+}
+
+// handleCursorKey applies a movement or editing key at the cursor
+func (e *Editor) handleCursorKey(r rune) {
+	switch r {
+	case ARROW_UP, ARROW_DOWN, ARROW_RIGHT, ARROW_LEFT, PAGE_UP, PAGE_DOWN, HOME, END,
+		CTRL | ARROW_LEFT, ALT | ARROW_LEFT, ALT | 'b',
+		CTRL | ARROW_RIGHT, ALT | ARROW_RIGHT, ALT | 'f',
+		CTRL | ARROW_UP, CTRL | ARROW_DOWN:
 		e.moveCursor(r)
-	case BACKSPACE, DELETE:
-		e.handleDelete()
-		e.setDirty()
+	case DELETE, BACKSPACE:
+		// most terminals send DEL for the Backspace key, some send Ctrl-H
+		e.handleBackspace()
+	case DEL_KEY:
+		e.handleForwardDelete()
+	case CTRL | DELETE, ALT | DELETE:
+		e.handleDeleteWordBackward()
+	case CTRL | DEL_KEY, ALT | 'd':
+		e.handleDeleteWordForward()
 	case RETURN:
 		e.handleNewLine()
 		e.setDirty()
@@ -380,8 +913,16 @@ func (e *Editor) handleEditModeKey(r rune) {
 	}
 }
 
-func (e *Editor) handleDelete() {
+// handleBackspace deletes the rune before the cursor, joining with the
+// previous line at the start of a line
+func (e *Editor) handleBackspace() {
 	row, col := e.cursor.coords()
+	if row == 0 && col == 0 {
+		return
+	}
+	if e.deletePair() {
+		return
+	}
 	if col == 0 {
 		newRow, newCol, err := e.document.mergeLines(row)
 		if e.handleError("failed to merge lines", err) {
@@ -396,6 +937,62 @@ func (e *Editor) handleDelete() {
 		}
 		e.moveLeft()
 	}
+	e.setDirty()
+}
+
+// handleForwardDelete deletes the rune under the cursor, joining the next
+// line at the end of a line
+func (e *Editor) handleForwardDelete() {
+	row, col := e.cursor.coords()
+	changed, err := e.document.deleteForward(row, col)
+	if e.handleError("failed to delete character", err) {
+		return
+	}
+	if changed {
+		e.setDirty()
+	}
+}
+
+// handleDeleteWordBackward deletes from the start of the previous word
+// to the cursor, joining with the previous line at the start of a line
+func (e *Editor) handleDeleteWordBackward() {
+	row, col := e.cursor.coords()
+	if col == 0 {
+		e.handleBackspace()
+		return
+	}
+	content, err := e.document.getLine(row)
+	if e.handleError("failed to read current line", err) {
+		return
+	}
+	start := wordStartBefore([]rune(content), col)
+	err = e.document.deleteRunes(row, start, col)
+	if e.handleError("failed to delete word", err) {
+		return
+	}
+	e.cursor.moveTo(row, start)
+	e.cursor.anchor = start
+	e.setDirty()
+}
+
+// handleDeleteWordForward deletes from the cursor to the end of the next
+// word, joining the next line at the end of a line
+func (e *Editor) handleDeleteWordForward() {
+	row, col := e.cursor.coords()
+	if col >= e.currentLineLength() {
+		e.handleForwardDelete()
+		return
+	}
+	content, err := e.document.getLine(row)
+	if e.handleError("failed to read current line", err) {
+		return
+	}
+	end := wordEndAfter([]rune(content), col)
+	err = e.document.deleteRunes(row, col, end)
+	if e.handleError("failed to delete word", err) {
+		return
+	}
+	e.setDirty()
 }
 
 func (e *Editor) handleNewLine() {
@@ -412,14 +1009,16 @@ func (e *Editor) handleNewLine() {
 // it either inserts a tab rune or expands it as spaces
 func (e *Editor) handleTab() {
 	row, col := e.cursor.coords()
-	if e.config.ExpandTabs {
-		spaces := e.config.TabSize - (col % e.config.TabSize)
-		for range spaces {
-			e.document.insertRune(row, col, ' ')
-			col++
+	indent := "\t"
+	if e.document.config.ExpandTabs {
+		tabSize := e.document.config.TabSize
+		indent = strings.Repeat(" ", tabSize-col%tabSize)
+	}
+	for _, r := range indent {
+		err := e.document.insertRune(row, col, r)
+		if e.handleError("failed to insert tab", err) {
+			break
 		}
-	} else {
-		e.document.insertRune(row, col, TAB)
 		col++
 	}
 	e.cursor.moveTo(row, col)
@@ -427,7 +1026,14 @@ func (e *Editor) handleTab() {
 }
 
 func (e *Editor) handlePrintableRune(r rune) {
+	if e.insertPaired(r) {
+		return
+	}
 	row, col := e.cursor.coords()
+	if e.document.config.AutoIndent && isCloser(r) {
+		col = e.dedentForCloser(row, col, r)
+		e.cursor.moveTo(row, col)
+	}
 	err := e.document.insertRune(row, col, r)
 	if e.handleError("failed to insert character", err) {
 		return
@@ -435,12 +1041,48 @@ func (e *Editor) handlePrintableRune(r rune) {
 	e.moveRight()
 }
 
-func (e *Editor) Start() int {
+// dedentForCloser removes one level of indentation when a closing bracket
+// is typed on an otherwise blank line that is indented deeper than the
+// matching opener, returning the new cursor column
+func (e *Editor) dedentForCloser(row, col int, closer rune) int {
+	content, err := e.document.getLine(row)
+	if err != nil || col == 0 || !isBlank(string([]rune(content)[:col])) {
+		return col
+	}
+	opener, ok := e.document.openerBefore(position{row, col}, closer)
+	if !ok {
+		return col
+	}
+	tabSize := e.document.config.TabSize
+	openerLine := e.document.lines[opener.row].content
+	openerWidth := e.cursor.calculateRenderCol(openerLine, tabSize, len([]rune(leadingWhitespace(openerLine))))
+	if e.cursor.calculateRenderCol(content, tabSize, col) <= openerWidth {
+		return col
+	}
+	removed, err := e.document.dedentLine(row)
+	if e.handleError("failed to dedent line", err) {
+		return col
+	}
+	return col - removed
+}
+
+// Start runs the editor until it quits; location is an optional
+// starting position as accepted by the goto command
+func (e *Editor) Start(location string) int {
 	e.document.LoadFromDisk()
+	if location != "" {
+		pos, err := parseLocation(location, e.cursorPosition(), e.document.lineCount())
+		if err == nil {
+			e.updateComponents()
+			e.gotoPosition(pos)
+		}
+	}
 	go e.readInputStream()
 
 	ticker := time.NewTicker(INPUT_TIMEOUT)
 	defer ticker.Stop()
+	configTicker := time.NewTicker(CONFIG_POLL_INTERVAL)
+	defer configTicker.Stop()
 
 	for {
 		select {
@@ -449,13 +1091,17 @@ func (e *Editor) Start() int {
 				return 1
 			}
 			e.processKeyPress(res.r)
+		case res := <-e.jobChan:
+			e.finishJob(res)
 		case <-ticker.C:
+		case <-configTicker.C:
+			e.reloadConfig()
 		case <-e.quitChan:
 			return e.exitCode
 		}
 
 		e.updateComponents()
-		e.view.Render(e.mode, e.document, e.config, e.cursor, e.finder, e.commands, len(e.buffer), e.status)
+		e.view.Render(e.frame())
 	}
 }
 
@@ -474,7 +1120,37 @@ func (e *Editor) updateComponents() {
 		e.requestShutdown(3)
 		return
 	}
-	e.cursor.updateRenderedPos(e.view, currentLine, e.config.TabSize)
+	e.cursor.updateRenderedPos(e.view, currentLine, e.document.config.TabSize)
+}
+
+// frame collects the state drawn by the view
+func (e *Editor) frame() *frame {
+	return &frame{
+		mode:      e.mode,
+		doc:       e.document,
+		cfg:       e.document.config,
+		cur:       e.cursor,
+		finder:    e.finder,
+		palette:   e.palette,
+		prompt:    e.prompt,
+		cmds:      e.commands,
+		marked:    e.markedRange(),
+		block:     e.blockRegion(),
+		match:     e.bracketMatch(),
+		cursors:   e.extraCursorPositions(),
+		ring:      e.killRing,
+		recording: e.macros.recording,
+		message:   e.message,
+		status:    e.status,
+	}
+}
+
+// markedRange returns the marked lines for rendering, or nil
+func (e *Editor) markedRange() *lineRange {
+	if r, ok := e.markedLines(); ok {
+		return &r
+	}
+	return nil
 }
 
 func (e *Editor) setDirty() {
@@ -489,6 +1165,13 @@ func (e *Editor) handleError(msg string, err error) bool {
 	return false
 }
 
+func (e *Editor) reportError(err error) {
+	if err == nil {
+		return
+	}
+	e.setStatus(fmt.Sprintf("Error: %v", err), 3)
+}
+
 func (e *Editor) setStatus(msg string, n int) {
 	e.status = msg
 	if n > 0 {
@@ -503,7 +1186,7 @@ func (e *Editor) clearStatus() {
 	e.status = ""
 }
 
-func Run(fileName string) int {
+func Run(fileName, location string, layers *ConfigLayers, readonly bool) int {
 	fmt.Print("\x1b[?1049h") // switch to alternate screen buffer
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
@@ -511,8 +1194,8 @@ func Run(fileName string) int {
 		return 1
 	}
 
-	editor := NewEditor(os.Stdin, fileName)
-	exitCode := editor.Start()
+	editor := NewEditor(os.Stdin, fileName, layers, readonly)
+	exitCode := editor.Start(location)
 
 	err = term.Restore(int(os.Stdin.Fd()), oldState)
 	if err != nil {
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const EDITORCONFIG = ".editorconfig"
+
+// editorConfigSection is a glob section of an .editorconfig file and its
+// properties, in the order they were written
+type editorConfigSection struct {
+	pattern *regexp.Regexp
+	keys    []string
+	values  map[string]string
+}
+
+type editorConfigFile struct {
+	root     bool
+	sections []editorConfigSection
+}
+
+// parseEditorConfig reads an .editorconfig file. Invalid lines are
+// skipped, as the EditorConfig specification asks.
+func parseEditorConfig(path string) (*editorConfigFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ecf := &editorConfigFile{}
+	dir := filepath.Dir(path)
+	var current *editorConfigSection
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			re, err := editorConfigGlob(dir, line[1:len(line)-1])
+			if err != nil {
+				current = nil
+				continue
+			}
+			ecf.sections = append(ecf.sections, editorConfigSection{pattern: re, values: make(map[string]string)})
+			current = &ecf.sections[len(ecf.sections)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if current == nil {
+			// the preamble before the first section only holds root
+			if key == "root" {
+				ecf.root = strings.EqualFold(value, "true")
+			}
+			continue
+		}
+		if _, seen := current.values[key]; !seen {
+			current.keys = append(current.keys, key)
+		}
+		current.values[key] = value
+	}
+	return ecf, scanner.Err()
+}
+
+// editorConfigGlob translates a section glob into a regular expression
+// matching absolute slash separated paths. A glob without a slash matches
+// a file name in any directory below dir.
+func editorConfigGlob(dir, glob string) (*regexp.Regexp, error) {
+	prefix := regexp.QuoteMeta(filepath.ToSlash(dir)) + "/"
+	if !strings.Contains(glob, "/") {
+		prefix += "(?:.*/)?"
+	}
+	glob = strings.TrimPrefix(glob, "/")
+
+	var builder strings.Builder
+	runes := []rune(glob)
+	braces := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch r {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				builder.WriteString(regexp.QuoteMeta(string(runes[i])))
+			}
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				builder.WriteString(".*")
+				i++
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		case '[':
+			end := indexFrom(runes, i+1, ']')
+			if end == -1 {
+				builder.WriteString(`\[`)
+				continue
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		case '{':
+			end := indexFrom(runes, i+1, '}')
+			if end != -1 {
+				body := string(runes[i+1 : end])
+				if lo, hi, ok := numericRange(body); ok {
+					builder.WriteString(numericRangePattern(lo, hi))
+					i = end
+					continue
+				}
+				if strings.Contains(body, ",") {
+					braces++
+					builder.WriteString("(?:")
+					continue
+				}
+			}
+			builder.WriteString(`\{`)
+		case ',':
+			if braces > 0 {
+				builder.WriteString("|")
+			} else {
+				builder.WriteString(",")
+			}
+		case '}':
+			if braces > 0 {
+				braces--
+				builder.WriteString(")")
+			} else {
+				builder.WriteString(`\}`)
+			}
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return regexp.Compile("^" + prefix + builder.String() + "$")
+}
+
+// indexFrom returns the index of the first r in runes at or after from, or -1
+func indexFrom(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// numericRange parses the body of a {num1..num2} glob
+func numericRange(body string) (int, int, bool) {
+	loText, hiText, ok := strings.Cut(body, "..")
+	if !ok {
+		return 0, 0, false
+	}
+	lo, err := strconv.Atoi(loText)
+	if err != nil {
+		return 0, 0, false
+	}
+	hi, err := strconv.Atoi(hiText)
+	if err != nil {
+		return 0, 0, false
+	}
+	return min(lo, hi), max(lo, hi), true
+}
+
+// numericRangePattern matches any integer between lo and hi
+func numericRangePattern(lo, hi int) string {
+	if hi-lo > 1000 {
+		// too many alternatives; accept any number
+		return `-?\d+`
+	}
+	alternatives := make([]string, 0, hi-lo+1)
+	for n := lo; n <= hi; n++ {
+		alternatives = append(alternatives, strconv.Itoa(n))
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// editorConfigFor collects the EditorConfig properties that apply to a
+// file, from the .editorconfig files in its directory and above it up to
+// one marked root. Closer files and later sections take precedence.
+func editorConfigFor(fileName string) map[string]string {
+	props := make(map[string]string)
+	if fileName == "" {
+		return props
+	}
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return props
+	}
+
+	var files []*editorConfigFile
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		ecf, err := parseEditorConfig(filepath.Join(dir, EDITORCONFIG))
+		if err == nil {
+			files = append(files, ecf)
+			if ecf.root {
+				break
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	target := filepath.ToSlash(path)
+	for i := len(files) - 1; i >= 0; i-- {
+		for _, section := range files[i].sections {
+			if !section.pattern.MatchString(target) {
+				continue
+			}
+			for _, key := range section.keys {
+				value := strings.ToLower(section.values[key])
+				if value == "unset" {
+					delete(props, key)
+					continue
+				}
+				props[key] = value
+			}
+		}
+	}
+	return props
+}
+
+// editorConfigSettings translates EditorConfig properties into gtext settings
+func editorConfigSettings(props map[string]string) []setting {
+	var settings []setting
+	add := func(key, value string) {
+		settings = append(settings, setting{key: key, value: value, origin: EDITORCONFIG})
+	}
+
+	expandTabs := false
+	switch props["indent_style"] {
+	case "tab":
+		add("expand_tabs", "false")
+	case "space":
+		expandTabs = true
+		add("expand_tabs", "true")
+	}
+
+	// gtext has a single width for tabs and indentation levels: spaces
+	// follow indent_size, tabs follow tab_width
+	indentSize, indentErr := strconv.Atoi(props["indent_size"])
+	tabWidth, tabErr := strconv.Atoi(props["tab_width"])
+	switch {
+	case expandTabs && indentErr == nil && indentSize > 0:
+		add("tab_size", strconv.Itoa(indentSize))
+	case tabErr == nil && tabWidth > 0:
+		add("tab_size", strconv.Itoa(tabWidth))
+	case indentErr == nil && indentSize > 0:
+		add("tab_size", strconv.Itoa(indentSize))
+	}
+
+	for _, key := range []string{"end_of_line", "charset", "trim_trailing_whitespace", "insert_final_newline", "max_line_length"} {
+		value, ok := props[key]
+		if !ok {
+			continue
+		}
+		if key == "max_line_length" && value == "off" {
+			value = "0"
+		}
+		add(key, value)
+	}
+	return settings
+}
@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+func TestEditorConfigGlob(t *testing.T) {
+	tests := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"*", "/p/a.go", true},
+		{"*", "/p/sub/a.go", true},
+		{"*.go", "/p/a.go", true},
+		{"*.go", "/p/sub/dir/a.go", true},
+		{"*.go", "/p/a.gox", false},
+		{"*.go", "/other/a.go", false},
+		{"sub/*.go", "/p/sub/a.go", true},
+		{"sub/*.go", "/p/sub/dir/a.go", false},
+		{"/sub/*.go", "/p/sub/a.go", true},
+		{"sub/**.go", "/p/sub/dir/a.go", true},
+		{"a?.txt", "/p/ab.txt", true},
+		{"a?.txt", "/p/a/.txt", false},
+		{"*.{js,ts}", "/p/a.ts", true},
+		{"*.{js,ts}", "/p/a.go", false},
+		{"{a,b}.{js,ts}", "/p/b.js", true},
+		{"[abc].md", "/p/b.md", true},
+		{"[!abc].md", "/p/b.md", false},
+		{"[!abc].md", "/p/d.md", true},
+		{"file{1..3}.txt", "/p/file2.txt", true},
+		{"file{1..3}.txt", "/p/file4.txt", false},
+		{"file{3..1}.txt", "/p/file1.txt", true},
+		{"{single}.txt", "/p/{single}.txt", true},
+		{`\*.txt`, "/p/*.txt", true},
+		{`\*.txt`, "/p/a.txt", false},
+		{"[unclosed.txt", "/p/[unclosed.txt", true},
+	}
+	for _, tt := range tests {
+		re, err := editorConfigGlob("/p", tt.glob)
+		if err != nil {
+			t.Errorf("editorConfigGlob(%q): %v", tt.glob, err)
+			continue
+		}
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("glob %q matching %q = %v, want %v (%s)", tt.glob, tt.path, got, tt.want, re)
+		}
+	}
+}
+
+func TestNumericRange(t *testing.T) {
+	tests := []struct {
+		body   string
+		lo, hi int
+		ok     bool
+	}{
+		{"1..3", 1, 3, true},
+		{"3..1", 1, 3, true},
+		{"-2..2", -2, 2, true},
+		{"0..0", 0, 0, true},
+		{"1..", 0, 0, false},
+		{"a..b", 0, 0, false},
+		{"1,3", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		lo, hi, ok := numericRange(tt.body)
+		if lo != tt.lo || hi != tt.hi || ok != tt.ok {
+			t.Errorf("numericRange(%q) = %d, %d, %v, want %d, %d, %v", tt.body, lo, hi, ok, tt.lo, tt.hi, tt.ok)
+		}
+	}
+}
+
+func TestEditorConfigSettings(t *testing.T) {
+	tests := []struct {
+		name  string
+		props map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "spaces follow indent_size",
+			props: map[string]string{"indent_style": "space", "indent_size": "2", "tab_width": "8"},
+			want:  map[string]string{"expand_tabs": "true", "tab_size": "2"},
+		},
+		{
+			name:  "tabs follow tab_width",
+			props: map[string]string{"indent_style": "tab", "indent_size": "2", "tab_width": "8"},
+			want:  map[string]string{"expand_tabs": "false", "tab_size": "8"},
+		},
+		{
+			name:  "max_line_length off",
+			props: map[string]string{"max_line_length": "off", "end_of_line": "crlf"},
+			want:  map[string]string{"max_line_length": "0", "end_of_line": "crlf"},
+		},
+	}
+	for _, tt := range tests {
+		got := map[string]string{}
+		for _, s := range editorConfigSettings(tt.props) {
+			got[s.key] = s.value
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for key, val := range tt.want {
+			if got[key] != val {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ErrNoFormatterOutput = gtextError("formatter produced no output")
+
+// shiftRow maps a row from before the hunks were applied to after; rows
+// inside a changed hunk stay at the same offset into it where possible
+func shiftRow(hunks []lineHunk, row int) int {
+	delta := 0
+	for _, h := range hunks {
+		if row < h.start {
+			break
+		}
+		if row < h.end {
+			return h.start + delta + min(row-h.start, max(len(h.lines)-1, 0))
+		}
+		delta += len(h.lines) - (h.end - h.start)
+	}
+	return row + delta
+}
+
+// applyLines changes the document to contents with as few line
+// replacements as possible, keeping the cursors, mark and scroll position
+// on the same text
+func (e *Editor) applyLines(contents []string) error {
+	hunks := diffHunks(e.document.contents(), contents)
+	if len(hunks) == 0 {
+		return nil
+	}
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+		err := e.document.replaceLines(h.start, h.end, h.lines)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range append([]*Cursor{e.cursor}, e.extraCursors...) {
+		row := min(shiftRow(hunks, c.row), e.document.lineCount()-1)
+		col := min(c.col, e.document.getLineLength(row))
+		c.moveTo(row, col)
+		c.anchor = col
+	}
+	if e.mark != nil {
+		e.mark.row = shiftRow(hunks, e.mark.row)
+	}
+	e.clearBlock()
+	e.view.rowOffset = max(shiftRow(hunks, e.view.rowOffset), 0)
+	e.setDirty()
+	return nil
+}
+
+// formatThen runs the formatter cfg has for the language of path on the
+// document, applies its changes and then calls save. If the formatter
+// fails, save is only called once the user confirms.
+func (e *Editor) formatThen(path string, cfg *Config, save func()) {
+	command, ok := cfg.formatterFor(detectLanguage(path).name)
+	if !ok {
+		save()
+		return
+	}
+	contents := e.document.contents()
+	input := strings.Join(contents, "\n") + "\n"
+	err := e.runShell(command, input, func(res shellResult) {
+		formatted := res.outputLines()
+		if res.err == nil && len(formatted) == 0 && strings.TrimSpace(input) != "" {
+			res.err = ErrNoFormatterOutput
+		}
+		if res.err == nil {
+			res.err = e.applyLines(formatted)
+		}
+		if res.err != nil {
+			e.message = res.failure(command)
+			e.confirm("Formatting failed, save unformatted?", save)
+			return
+		}
+		save()
+	})
+	if err != nil {
+		e.reportError(fmt.Errorf("could not format: %w", err))
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestShiftRow(t *testing.T) {
+	// "a b c d e" becomes "a x y c e"
+	hunks := []lineHunk{{1, 2, []string{"x", "y"}}, {3, 4, nil}}
+	tests := []struct{ row, want int }{
+		{0, 0},
+		{1, 1},
+		{2, 3},
+		{3, 4}, // deleted, now the line after it
+		{4, 4},
+	}
+	for _, tt := range tests {
+		if got := shiftRow(hunks, tt.row); got != tt.want {
+			t.Errorf("shiftRow(%d) = %d, want %d", tt.row, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	JUMPLIST_SIZE = 100
+
+	ErrInvalidLocation = gtextError("invalid location")
+)
+
+type jump struct {
+	fileName string
+	pos      position
+}
+
+// JumpList remembers where the cursor was before large jumps so they can
+// be walked back and forth like browser history
+type JumpList struct {
+	entries []jump
+	current int
+}
+
+// record adds a jump origin, discarding any entries ahead of the current one
+func (j *JumpList) record(entry jump) {
+	j.entries = j.entries[:j.current]
+	if n := len(j.entries); n > 0 && j.entries[n-1] == entry {
+		return
+	}
+	j.entries = append(j.entries, entry)
+	if len(j.entries) > JUMPLIST_SIZE {
+		j.entries = j.entries[1:]
+	}
+	j.current = len(j.entries)
+}
+
+// back returns the previous jump origin; from is remembered so that
+// forward can return to it
+func (j *JumpList) back(from jump) (jump, bool) {
+	if j.current == 0 {
+		return jump{}, false
+	}
+	if j.current == len(j.entries) {
+		j.entries = append(j.entries, from)
+	}
+	j.current--
+	return j.entries[j.current], true
+}
+
+func (j *JumpList) forward() (jump, bool) {
+	if j.current >= len(j.entries)-1 {
+		return jump{}, false
+	}
+	j.current++
+	return j.entries[j.current], true
+}
+
+// parseLocation resolves a goto target relative to the cursor position:
+// "line", "line:col", "+N"/"-N" for relative lines and "N%" for a
+// percentage of the document. Lines and columns are 1-indexed.
+func parseLocation(spec string, cur position, lineCount int) (position, error) {
+	spec = strings.TrimSpace(spec)
+	invalid := fmt.Errorf("%w: %q", ErrInvalidLocation, spec)
+	if spec == "" {
+		return position{}, invalid
+	}
+
+	if pct, ok := strings.CutSuffix(spec, "%"); ok {
+		n, err := strconv.Atoi(pct)
+		if err != nil || n < 0 || n > 100 {
+			return position{}, invalid
+		}
+		return position{row: (lineCount - 1) * n / 100, col: 0}, nil
+	}
+
+	rowSpec, colSpec, hasCol := strings.Cut(spec, ":")
+	n, err := strconv.Atoi(rowSpec)
+	if err != nil {
+		return position{}, invalid
+	}
+	row := n - 1
+	if strings.HasPrefix(rowSpec, "+") || strings.HasPrefix(rowSpec, "-") {
+		row = cur.row + n
+	}
+
+	col := 0
+	if hasCol {
+		c, err := strconv.Atoi(colSpec)
+		if err != nil || c < 1 {
+			return position{}, invalid
+		}
+		col = c - 1
+	}
+
+	row = min(max(row, 0), lineCount-1)
+	return position{row: row, col: col}, nil
+}
+
+// splitFileLocation splits a "file:line:col" command line argument;
+// line and col are 0 when absent
+func splitFileLocation(arg string) (fileName string, line, col int) {
+	fileName = arg
+	parts := strings.Split(arg, ":")
+	numbers := []int{}
+	for len(parts) > 1 && len(numbers) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil || n < 1 {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+		parts = parts[:len(parts)-1]
+	}
+	if len(numbers) == 0 {
+		return arg, 0, 0
+	}
+	fileName = strings.Join(parts, ":")
+	line = numbers[0]
+	if len(numbers) == 2 {
+		col = numbers[1]
+	}
+	return fileName, line, col
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLocation(t *testing.T) {
+	cur := position{row: 10, col: 4}
+	tests := []struct {
+		spec string
+		want position
+		err  error
+	}{
+		{"5", position{4, 0}, nil},
+		{" 5:3 ", position{4, 2}, nil},
+		{"+3", position{13, 0}, nil},
+		{"-3:2", position{7, 1}, nil},
+		{"-20", position{0, 0}, nil},
+		{"0", position{0, 0}, nil},
+		{"500", position{99, 0}, nil},
+		{"0%", position{0, 0}, nil},
+		{"50%", position{49, 0}, nil},
+		{"100%", position{99, 0}, nil},
+		{"", position{}, ErrInvalidLocation},
+		{"abc", position{}, ErrInvalidLocation},
+		{"5:0", position{}, ErrInvalidLocation},
+		{"5:x", position{}, ErrInvalidLocation},
+		{"101%", position{}, ErrInvalidLocation},
+	}
+	for _, tt := range tests {
+		got, err := parseLocation(tt.spec, cur, 100)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("parseLocation(%q) error = %v, want %v", tt.spec, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseLocation(%q) = %v, %v, want %v", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestSplitFileLocation(t *testing.T) {
+	tests := []struct {
+		arg       string
+		fileName  string
+		line, col int
+	}{
+		{"a.go", "a.go", 0, 0},
+		{"a.go:12", "a.go", 12, 0},
+		{"a.go:12:3", "a.go", 12, 3},
+		{"a.go:1:2:3", "a.go:1", 2, 3},
+		{"a:b.go:4", "a:b.go", 4, 0},
+		{"a.go:x", "a.go:x", 0, 0},
+		{"a.go:0", "a.go:0", 0, 0},
+		{"12", "12", 0, 0},
+	}
+	for _, tt := range tests {
+		fileName, line, col := splitFileLocation(tt.arg)
+		if fileName != tt.fileName || line != tt.line || col != tt.col {
+			t.Errorf("splitFileLocation(%q) = %q, %d, %d, want %q, %d, %d", tt.arg, fileName, line, col, tt.fileName, tt.line, tt.col)
+		}
+	}
+}
+
+func TestJumpList(t *testing.T) {
+	at := func(row int) jump { return jump{fileName: "a.txt", pos: position{row: row}} }
+	var j JumpList
+
+	if _, ok := j.back(at(0)); ok {
+		t.Fatal("back on an empty jump list succeeded")
+	}
+	j.record(at(1))
+	j.record(at(1))
+	j.record(at(2))
+
+	steps := []struct {
+		name string
+		move func() (jump, bool)
+		want jump
+		ok   bool
+	}{
+		{"back", func() (jump, bool) { return j.back(at(3)) }, at(2), true},
+		{"back", func() (jump, bool) { return j.back(at(2)) }, at(1), true},
+		{"back at the start", func() (jump, bool) { return j.back(at(1)) }, jump{}, false},
+		{"forward", j.forward, at(2), true},
+		{"forward to where back started", j.forward, at(3), true},
+		{"forward at the end", j.forward, jump{}, false},
+	}
+	for i, s := range steps {
+		got, ok := s.move()
+		if got != s.want || ok != s.ok {
+			t.Errorf("step %d (%s) = %v, %v, want %v, %v", i, s.name, got, ok, s.want, s.ok)
+		}
+	}
+
+	// recording after going back drops the entries ahead
+	j.back(at(3))
+	j.record(at(7))
+	if got, _ := j.back(at(8)); got != at(7) {
+		t.Errorf("back after record = %v, want %v", got, at(7))
+	}
+	if got, _ := j.back(at(7)); got != at(1) {
+		t.Errorf("second back after record = %v, want %v", got, at(1))
+	}
+
+	for row := range JUMPLIST_SIZE + 10 {
+		j.record(at(row))
+	}
+	if len(j.entries) != JUMPLIST_SIZE {
+		t.Errorf("jump list holds %d entries, want %d", len(j.entries), JUMPLIST_SIZE)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+// KillRing keeps the most recent cuts and copies, newest last, so that
+// older ones can be pasted again by cycling through them
+type KillRing struct {
+	entries [][]string
+	size    int
+	yankIdx int
+}
+
+func NewKillRing(size int) *KillRing {
+	return &KillRing{size: max(size, 1)}
+}
+
+func (k *KillRing) len() int {
+	return len(k.entries)
+}
+
+// push adds a new entry, dropping the oldest once the ring is full
+func (k *KillRing) push(lines []string) {
+	k.entries = append(k.entries, lines)
+	if len(k.entries) > k.size {
+		k.entries = k.entries[len(k.entries)-k.size:]
+	}
+	k.yankIdx = len(k.entries) - 1
+}
+
+// appendToNewest extends the newest entry, used for consecutive cuts
+func (k *KillRing) appendToNewest(lines []string) {
+	if len(k.entries) == 0 {
+		k.push(lines)
+		return
+	}
+	last := len(k.entries) - 1
+	k.entries[last] = append(k.entries[last], lines...)
+	k.yankIdx = last
+}
+
+// newest returns the most recent entry and resets the yank position to it
+func (k *KillRing) newest() ([]string, bool) {
+	if len(k.entries) == 0 {
+		return nil, false
+	}
+	k.yankIdx = len(k.entries) - 1
+	return k.entries[k.yankIdx], true
+}
+
+// previous moves the yank position to the next older entry,
+// wrapping around to the newest
+func (k *KillRing) previous() ([]string, bool) {
+	if len(k.entries) == 0 {
+		return nil, false
+	}
+	k.yankIdx--
+	if k.yankIdx < 0 {
+		k.yankIdx = len(k.entries) - 1
+	}
+	return k.entries[k.yankIdx], true
+}
+
+// resize changes the capacity, dropping the oldest entries if needed
+func (k *KillRing) resize(size int) {
+	k.size = max(size, 1)
+	if dropped := len(k.entries) - k.size; dropped > 0 {
+		k.entries = k.entries[dropped:]
+		// keep pointing at the same entry, or the oldest one left if it was dropped
+		k.yankIdx = max(k.yankIdx-dropped, 0)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKillRing(t *testing.T) {
+	k := NewKillRing(3)
+	if _, ok := k.newest(); ok {
+		t.Fatal("newest on an empty ring succeeded")
+	}
+	if _, ok := k.previous(); ok {
+		t.Fatal("previous on an empty ring succeeded")
+	}
+
+	k.appendToNewest([]string{"a"})
+	k.push([]string{"b"})
+	k.appendToNewest([]string{"b2"})
+	k.push([]string{"c"})
+	k.push([]string{"d"})
+
+	steps := []struct {
+		name string
+		move func() ([]string, bool)
+		want []string
+	}{
+		{"newest", k.newest, []string{"d"}},
+		{"previous", k.previous, []string{"c"}},
+		{"previous again", k.previous, []string{"b", "b2"}},
+		{"wraps to the newest", k.previous, []string{"d"}},
+		{"previous after wrapping", k.previous, []string{"c"}},
+		{"newest resets", k.newest, []string{"d"}},
+	}
+	for _, s := range steps {
+		got, ok := s.move()
+		if !ok || !slices.Equal(got, s.want) {
+			t.Errorf("%s = %q, %v, want %q", s.name, got, ok, s.want)
+		}
+	}
+	if k.len() != 3 {
+		t.Errorf("len = %d, want the size 3", k.len())
+	}
+}
+
+func TestKillRingResize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		yankIdx int
+		want    []string // entry at the yank position after resizing
+		len     int
+	}{
+		{"grow", 10, 1, []string{"b"}, 4},
+		{"shrink keeps the yanked entry", 2, 3, []string{"d"}, 2},
+		{"shrink drops the yanked entry", 2, 0, []string{"c"}, 2},
+		{"at least one entry", 0, 3, []string{"d"}, 1},
+	}
+	for _, tt := range tests {
+		k := NewKillRing(4)
+		for _, s := range []string{"a", "b", "c", "d"} {
+			k.push([]string{s})
+		}
+		k.yankIdx = tt.yankIdx
+		k.resize(tt.size)
+		if k.len() != tt.len || !slices.Equal(k.entries[k.yankIdx], tt.want) {
+			t.Errorf("%s: %d entries yanking %q, want %d yanking %q", tt.name, k.len(), k.entries[k.yankIdx], tt.len, tt.want)
+		}
+	}
+}
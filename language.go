@@ -0,0 +1,138 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Language describes the lexical details of a file type that the editor
+// needs: how comments and string literals are written, and how blocks are
+// indented
+type Language struct {
+	name         string
+	extensions   []string
+	fileNames    []string
+	lineComment  string
+	blockComment [2]string
+	quotes       string // runes delimiting single line strings, with backslash escapes
+	rawQuotes    string // runes delimiting strings that may span lines, without escapes
+	colonIndents bool   // a trailing colon opens an indented block
+}
+
+var plainText = &Language{name: "text"}
+
+var languages = []*Language{
+	{name: "go", extensions: []string{".go"}, lineComment: "//", blockComment: [2]string{"/*", "*/"}, quotes: `"'`, rawQuotes: "`"},
+	{name: "c", extensions: []string{".c", ".h", ".cc", ".cpp", ".cxx", ".hpp", ".m"}, lineComment: "//", blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+	{name: "java", extensions: []string{".java", ".kt", ".kts", ".scala", ".cs", ".swift", ".dart"}, lineComment: "//", blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+	{name: "javascript", extensions: []string{".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx"}, lineComment: "//", blockComment: [2]string{"/*", "*/"}, quotes: `"'`, rawQuotes: "`"},
+	{name: "rust", extensions: []string{".rs"}, lineComment: "//", blockComment: [2]string{"/*", "*/"}, quotes: `"`},
+	{name: "css", extensions: []string{".css", ".scss", ".less"}, blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+	{name: "python", extensions: []string{".py", ".pyw"}, lineComment: "#", quotes: `"'`, colonIndents: true},
+	{name: "shell", extensions: []string{".sh", ".bash", ".zsh", ".fish"}, lineComment: "#", quotes: `"'`},
+	{name: "ruby", extensions: []string{".rb"}, fileNames: []string{"Gemfile", "Rakefile"}, lineComment: "#", quotes: `"'`},
+	{name: "perl", extensions: []string{".pl", ".pm"}, lineComment: "#", quotes: `"'`},
+	{name: "yaml", extensions: []string{".yml", ".yaml"}, lineComment: "#", quotes: `"'`, colonIndents: true},
+	{name: "toml", extensions: []string{".toml", ".conf", ".cfg"}, fileNames: []string{".gtext.conf", ".editorconfig"}, lineComment: "#", quotes: `"'`},
+	{name: "make", extensions: []string{".mk"}, fileNames: []string{"Makefile", "makefile", "GNUmakefile"}, lineComment: "#", quotes: `"'`},
+	{name: "dockerfile", fileNames: []string{"Dockerfile"}, lineComment: "#", quotes: `"'`},
+	{name: "lua", extensions: []string{".lua"}, lineComment: "--", blockComment: [2]string{"--[[", "]]"}, quotes: `"'`},
+	{name: "sql", extensions: []string{".sql"}, lineComment: "--", blockComment: [2]string{"/*", "*/"}, quotes: `"'`},
+	{name: "haskell", extensions: []string{".hs", ".elm"}, lineComment: "--", blockComment: [2]string{"{-", "-}"}, quotes: `"`},
+	{name: "lisp", extensions: []string{".lisp", ".el", ".clj", ".cljs", ".scm", ".rkt"}, lineComment: ";", quotes: `"`},
+	{name: "assembly", extensions: []string{".asm", ".s"}, lineComment: ";", quotes: `"'`},
+	{name: "ini", extensions: []string{".ini"}, lineComment: ";", quotes: `"`},
+	{name: "html", extensions: []string{".html", ".htm", ".xml", ".svg", ".vue"}, blockComment: [2]string{"<!--", "-->"}, quotes: `"'`},
+	{name: "markdown", extensions: []string{".md", ".markdown"}, blockComment: [2]string{"<!--", "-->"}},
+	{name: "json", extensions: []string{".json"}, quotes: `"`},
+}
+
+// detectLanguage picks the language of a file from its name or extension
+func detectLanguage(fileName string) *Language {
+	base := filepath.Base(fileName)
+	ext := strings.ToLower(filepath.Ext(base))
+	for _, lang := range languages {
+		for _, name := range lang.fileNames {
+			if base == name {
+				return lang
+			}
+		}
+	}
+	for _, lang := range languages {
+		for _, e := range lang.extensions {
+			if ext == e {
+				return lang
+			}
+		}
+	}
+	return plainText
+}
+
+// isLanguageName reports whether name is the name of a known language
+func isLanguageName(name string) bool {
+	if name == plainText.name {
+		return true
+	}
+	for _, lang := range languages {
+		if lang.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lexState is the lexer state carried from one line to the next
+type lexState struct {
+	inBlockComment bool
+	rawQuote       rune
+}
+
+// scanLine walks a line of code, calling visit for every rune that is not
+// part of a comment or string literal, and returns the state at the end
+// of the line. It is deliberately simple: good enough to skip brackets in
+// strings and comments, not to highlight syntax.
+func (lang *Language) scanLine(runes []rune, state lexState, visit func(col int, r rune)) lexState {
+	var quote rune
+	for col := 0; col < len(runes); col++ {
+		r := runes[col]
+		switch {
+		case state.inBlockComment:
+			if hasPrefixAt(runes, col, lang.blockComment[1]) {
+				state.inBlockComment = false
+				col += len([]rune(lang.blockComment[1])) - 1
+			}
+		case state.rawQuote != 0:
+			if r == state.rawQuote {
+				state.rawQuote = 0
+			}
+		case quote != 0:
+			if r == '\\' {
+				col++
+			} else if r == quote {
+				quote = 0
+			}
+		case lang.blockComment[0] != "" && hasPrefixAt(runes, col, lang.blockComment[0]):
+			state.inBlockComment = true
+			col += len([]rune(lang.blockComment[0])) - 1
+		case lang.lineComment != "" && hasPrefixAt(runes, col, lang.lineComment):
+			return state
+		case strings.ContainsRune(lang.rawQuotes, r):
+			state.rawQuote = r
+		case strings.ContainsRune(lang.quotes, r):
+			quote = r
+		default:
+			visit(col, r)
+		}
+	}
+	return state
+}
+
+func hasPrefixAt(runes []rune, col int, prefix string) bool {
+	for _, p := range prefix {
+		if col >= len(runes) || runes[col] != p {
+			return false
+		}
+		col++
+	}
+	return true
+}
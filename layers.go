@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	FILETYPE_SECTION = "filetype."
+	// CONFIG_POLL_INTERVAL is how often config files are checked for changes
+	CONFIG_POLL_INTERVAL = time.Second
+)
+
+const (
+	ErrUnknownScope = gtextError("unknown scope, expected user or filetype")
+	ErrNoHomeDir    = gtextError("no home directory")
+	ErrNoConfig     = gtextError("config files are disabled by --no-config")
+)
+
+// setting is a single key=value assignment and where it came from
+type setting struct {
+	key, value string
+	origin     string
+}
+
+// configFile is a parsed gtext config file: settings for all files and
+// [filetype.<language>] sections, and the problems found in it as
+// "file:line: message"
+type configFile struct {
+	path        string
+	modTime     time.Time
+	settings    []setting
+	filetypes   map[string][]setting
+	diagnostics []string
+}
+
+// ConfigLayers resolves the settings for a file from, in increasing order
+// of precedence: the built-in defaults, the user config file and its
+// filetype sections, .editorconfig files, the project config file and its
+// filetype sections, command line flags, and settings changed with set
+type ConfigLayers struct {
+	userPath string
+	noFiles  bool // only defaults and flags, see --no-config
+	user     *configFile
+	cli      []setting
+	session  []setting
+}
+
+// parseConfigFile reads key=value lines and [filetype.<language>] sections.
+// Malformed lines, unknown keys and sections, and invalid values are
+// skipped and reported in diagnostics.
+func parseConfigFile(path string) (*configFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	cf := &configFile{path: path, modTime: info.ModTime(), filetypes: make(map[string][]setting)}
+	section := ""
+	validSection := true
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		location := fmt.Sprintf("%s:%d", displayPath(path), lineNum)
+		report := func(format string, args ...any) {
+			cf.diagnostics = append(cf.diagnostics, location+": "+fmt.Sprintf(format, args...))
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			lang, ok := strings.CutPrefix(section, FILETYPE_SECTION)
+			validSection = ok && isLanguageName(lang)
+			switch {
+			case !ok:
+				report("unknown section [%s], expected [%s<language>]", section, FILETYPE_SECTION)
+			case !validSection:
+				report("unknown language %q in [%s]", lang, section)
+			}
+			continue
+		}
+
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			report("expected key=value or [section], got %q", line)
+			continue
+		}
+		if !validSection {
+			continue
+		}
+		s := setting{key: strings.TrimSpace(key), value: strings.TrimSpace(val), origin: location}
+		err := checkSetting(s.key, s.value)
+		if err != nil {
+			if suggestion := suggestKey(s.key); errors.Is(err, ErrUnknownConfigKey) && suggestion != "" {
+				report("%v, did you mean %s?", err, suggestion)
+			} else {
+				report("%v", err)
+			}
+			continue
+		}
+		if section == "" {
+			cf.settings = append(cf.settings, s)
+			continue
+		}
+		lang := strings.TrimPrefix(section, FILETYPE_SECTION)
+		s.origin += fmt.Sprintf(" [%s]", section)
+		cf.filetypes[lang] = append(cf.filetypes[lang], s)
+	}
+	return cf, scanner.Err()
+}
+
+// displayPath shortens paths in the home directory to ~/...
+func displayPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return path
+}
+
+// settingsFor returns the settings of the file followed by those of the
+// section for a language
+func (cf *configFile) settingsFor(lang string) []setting {
+	if cf == nil {
+		return nil
+	}
+	return append(slices.Clone(cf.settings), cf.filetypes[lang]...)
+}
+
+func userConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, CONFIGFILE)
+}
+
+// loadConfigLayers reads the user config file at path, or ~/.gtext.conf
+// when path is empty. With noFiles no config files are read at all.
+func loadConfigLayers(path string, noFiles bool) *ConfigLayers {
+	if path == "" {
+		path = userConfigPath()
+	}
+	layers := &ConfigLayers{userPath: path, noFiles: noFiles}
+	layers.loadUser()
+	return layers
+}
+
+// loadUser (re)reads the user config file
+func (l *ConfigLayers) loadUser() {
+	if l.userPath == "" || l.noFiles {
+		return
+	}
+	l.user, _ = parseConfigFile(l.userPath)
+}
+
+// modTime returns the modification time of a file, or the zero time if
+// it does not exist
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// outdated reports whether a config file the settings were resolved from
+// was modified, created or removed since
+func (c *Config) outdated() bool {
+	for path, stamp := range c.stamps {
+		if !modTime(path).Equal(stamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// forget drops the session settings of a key
+func (l *ConfigLayers) forget(key string) {
+	l.session = slices.DeleteFunc(l.session, func(s setting) bool {
+		return s.key == key
+	})
+}
+
+// projectConfigFor finds the nearest .gtext.conf in the directory of a
+// file or above it, other than the user config file and ~/.gtext.conf.
+// Every place looked at is stamped, so that a file created there later
+// is noticed.
+func (l *ConfigLayers) projectConfigFor(fileName string, stamps map[string]time.Time) *configFile {
+	if l.noFiles {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	if fileName != "" {
+		path, err := filepath.Abs(fileName)
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Dir(path)
+	}
+	home := userConfigPath()
+	for {
+		path := filepath.Join(dir, CONFIGFILE)
+		if path != l.userPath && path != home {
+			cf, err := parseConfigFile(path)
+			if err == nil {
+				stamps[path] = cf.modTime
+				return cf
+			}
+			stamps[path] = modTime(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// resolve computes the settings for a file, recording the origin of
+// every value that is not a default. Invalid settings are skipped and
+// reported in the warnings.
+func (l *ConfigLayers) resolve(fileName string) *Config {
+	cfg := DefaultConfig()
+	cfg.origins = make(map[string]string)
+	cfg.stamps = make(map[string]time.Time)
+	lang := detectLanguage(fileName).name
+
+	if l.userPath != "" && !l.noFiles {
+		cfg.stamps[l.userPath] = time.Time{}
+		if l.user != nil {
+			cfg.stamps[l.userPath] = l.user.modTime
+		}
+	}
+	project := l.projectConfigFor(fileName, cfg.stamps)
+	settings := l.user.settingsFor(lang)
+	if !l.noFiles {
+		settings = append(settings, editorConfigSettings(editorConfigFor(fileName))...)
+	}
+	settings = append(settings, project.settingsFor(lang)...)
+	settings = append(settings, l.cli...)
+	settings = append(settings, l.session...)
+
+	for _, s := range settings {
+		err := cfg.set(s.key, s.value)
+		if err != nil {
+			// config files are checked as they are parsed, .editorconfig
+			// values only here
+			cfg.warnings = append(cfg.warnings, fmt.Sprintf("%s: %v", s.origin, err))
+			continue
+		}
+		cfg.origins[s.key] = s.origin
+	}
+	for _, cf := range []*configFile{l.user, project} {
+		if cf != nil {
+			cfg.warnings = append(cfg.warnings, cf.diagnostics...)
+		}
+	}
+	return cfg
+}
+
+// origin returns where the effective value of a setting came from
+func (c *Config) origin(key string) string {
+	if origin, ok := c.origins[key]; ok {
+		return origin
+	}
+	return "default"
+}
+
+// describe lists every setting with its value and origin
+func (c *Config) describe() []string {
+	keys := slices.Clone(configKeys)
+	for lang := range c.AutoPairsByLanguage {
+		keys = append(keys, "auto_pairs."+lang)
+	}
+	for lang := range c.Formatters {
+		keys = append(keys, "formatter."+lang)
+	}
+	slices.Sort(keys[len(configKeys):])
+
+	entries := make([]string, len(keys))
+	width := 0
+	for i, key := range keys {
+		entries[i] = key + "=" + c.get(key)
+		width = max(width, len(entries[i]))
+	}
+	lines := make([]string, 0, len(keys))
+	for i, key := range keys {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, entries[i], c.origin(key)))
+	}
+	return lines
+}
+
+// saveSetting assigns key in a section of a config file, replacing an
+// existing assignment or adding one at the end of the section. Everything
+// else in the file is kept as written.
+func saveSetting(path, section, key, val string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	assignment := key + "=" + val
+
+	current := ""
+	found, last := -1, -1
+	inSection := section == ""
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if current == section {
+				inSection = true
+				last = i
+			}
+			continue
+		}
+		if current != section || line == "" {
+			continue
+		}
+		last = i
+		if k, _, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == key {
+			found = i
+		}
+	}
+
+	switch {
+	case found != -1:
+		lines[found] = assignment
+	case inSection:
+		lines = slices.Insert(lines, last+1, assignment)
+	default:
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", assignment)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
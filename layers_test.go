@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(content), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveSetting(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		section  string
+		key, val string
+		want     string
+	}{
+		{
+			name: "new file",
+			key:  "tab_size", val: "2",
+			want: "tab_size=2\n",
+		},
+		{
+			name:   "replace",
+			before: "# mine\ntab_size=4\nexpand_tabs=true\n",
+			key:    "tab_size", val: "8",
+			want: "# mine\ntab_size=8\nexpand_tabs=true\n",
+		},
+		{
+			name:   "append before sections",
+			before: "tab_size=4\n\n[filetype.go]\nexpand_tabs=false\n",
+			key:    "auto_indent", val: "false",
+			want: "tab_size=4\nauto_indent=false\n\n[filetype.go]\nexpand_tabs=false\n",
+		},
+		{
+			name:    "existing section",
+			before:  "tab_size=4\n\n[filetype.go]\nexpand_tabs=false\n\n[filetype.yaml]\ntab_size=2\n",
+			section: "filetype.go", key: "tab_size", val: "8",
+			want: "tab_size=4\n\n[filetype.go]\nexpand_tabs=false\ntab_size=8\n\n[filetype.yaml]\ntab_size=2\n",
+		},
+		{
+			name:    "new section",
+			before:  "tab_size=4\n",
+			section: "filetype.python", key: "tab_size", val: "4",
+			want: "tab_size=4\n\n[filetype.python]\ntab_size=4\n",
+		},
+		{
+			name:    "same key in another section",
+			before:  "tab_size=4\n\n[filetype.go]\ntab_size=8\n",
+			section: "", key: "tab_size", val: "2",
+			want: "tab_size=2\n\n[filetype.go]\ntab_size=8\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), CONFIGFILE)
+			if tt.before != "" {
+				writeFile(t, path, tt.before)
+			}
+			err := saveSetting(path, tt.section, tt.key, tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", data, tt.want)
+			}
+
+			// the saved value is what the file now resolves to
+			cf, err := parseConfigFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cf.diagnostics) > 0 {
+				t.Errorf("diagnostics: %v", cf.diagnostics)
+			}
+			lang := ""
+			if tt.section != "" {
+				lang = tt.section[len(FILETYPE_SECTION):]
+			}
+			cfg := DefaultConfig()
+			for _, s := range cf.settingsFor(lang) {
+				cfg.set(s.key, s.value)
+			}
+			if got := cfg.get(tt.key); got != tt.val {
+				t.Errorf("%s resolves to %q, want %q", tt.key, got, tt.val)
+			}
+		})
+	}
+}
+
+func TestParseConfigFileDiagnostics(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), CONFIGFILE)
+	writeFile(t, path, `# comment
+tab_size=2
+tabsize=3
+expand_tabs=maybe
+no equals sign
+[filetype.go]
+tab_size=8
+[filetype.cobol]
+tab_size=6
+[colors]
+`)
+	cf, err := parseConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		path + ":3: unknown config key: tabsize, did you mean tab_size?",
+		path + ":4: invalid config value: expand_tabs must be true or false",
+		path + `:5: expected key=value or [section], got "no equals sign"`,
+		path + `:8: unknown language "cobol" in [filetype.cobol]`,
+		path + ":10: unknown section [colors], expected [filetype.<language>]",
+	}
+	if !slices.Equal(cf.diagnostics, want) {
+		t.Errorf("diagnostics:\n%q\nwant\n%q", cf.diagnostics, want)
+	}
+	if len(cf.settings) != 1 || cf.settings[0].key != "tab_size" {
+		t.Errorf("settings = %v, want only tab_size", cf.settings)
+	}
+	if got := cf.filetypes["go"]; len(got) != 1 || got[0].value != "8" {
+		t.Errorf("go section = %v, want tab_size=8", got)
+	}
+	if _, ok := cf.filetypes["cobol"]; ok {
+		t.Error("settings of an unknown language were kept")
+	}
+}
+
+func TestResolveOrder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	userPath := filepath.Join(home, CONFIGFILE)
+	writeFile(t, userPath, `tab_size=2
+scroll_margin=1
+max_line_length=10
+auto_indent=false
+[filetype.go]
+tab_size=3
+`)
+	project := filepath.Join(t.TempDir(), "project")
+	writeFile(t, filepath.Join(project, EDITORCONFIG), "root = true\n[*.go]\nindent_style = space\nindent_size = 5\n")
+	writeFile(t, filepath.Join(project, CONFIGFILE), "scroll_margin=7\nmax_line_length=20\n[filetype.go]\nexpand_tabs=false\n")
+
+	layers := loadConfigLayers("", false)
+	layers.cli = []setting{{key: "max_line_length", value: "80", origin: "--max-line-length"}}
+	layers.session = []setting{{key: "auto_indent", value: "true", origin: "set"}}
+
+	tests := []struct {
+		file, key  string
+		want, from string
+	}{
+		{"a.go", "tab_size", "5", EDITORCONFIG},
+		{"a.go", "expand_tabs", "false", filepath.Join(project, CONFIGFILE) + ":4 [filetype.go]"},
+		{"a.go", "scroll_margin", "7", filepath.Join(project, CONFIGFILE) + ":1"},
+		{"a.go", "max_line_length", "80", "--max-line-length"},
+		{"a.go", "auto_indent", "true", "set"},
+		{"a.go", "auto_pairs", "true", "default"},
+		{"a.txt", "tab_size", "2", "~/" + CONFIGFILE + ":1"},
+		{"a.txt", "expand_tabs", "false", "default"},
+	}
+	for _, tt := range tests {
+		cfg := layers.resolve(filepath.Join(project, tt.file))
+		if got, from := cfg.get(tt.key), cfg.origin(tt.key); got != tt.want || from != tt.from {
+			t.Errorf("%s: %s=%s from %s, want %s from %s", tt.file, tt.key, got, from, tt.want, tt.from)
+		}
+	}
+}
+
+func TestResolveNoFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(t, filepath.Join(home, CONFIGFILE), "tab_size=2\n")
+
+	layers := loadConfigLayers("", true)
+	cfg := layers.resolve(filepath.Join(home, "a.txt"))
+	if cfg.TabSize != DefaultConfig().TabSize {
+		t.Errorf("tab_size = %d with --no-config, want the default", cfg.TabSize)
+	}
+}
+
+func TestResolveEditorConfigWarnings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	writeFile(t, filepath.Join(project, EDITORCONFIG), "root = true\n[*]\ncharset = utf-16be\nend_of_line = crlf\n")
+
+	cfg := loadConfigLayers("", false).resolve(filepath.Join(project, "a.txt"))
+	want := []string{EDITORCONFIG + ": invalid config value: charset must be utf-8, utf-8-bom or latin1"}
+	if !slices.Equal(cfg.warnings, want) {
+		t.Errorf("warnings = %q, want %q", cfg.warnings, want)
+	}
+	if from := cfg.origin("charset"); from != "default" {
+		t.Errorf("charset from %s, want the default", from)
+	}
+	if from := cfg.origin("end_of_line"); from != EDITORCONFIG {
+		t.Errorf("end_of_line from %s, want %s", from, EDITORCONFIG)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// lineRange is an inclusive range of document rows
+type lineRange struct {
+	start, end int
+}
+
+func (r lineRange) contains(row int) bool {
+	return row >= r.start && row <= r.end
+}
+
+func (r lineRange) count() int {
+	return r.end - r.start + 1
+}
+
+// handleMark starts marking lines at the cursor, or clears the mark
+func (e *Editor) handleMark() {
+	if e.mark != nil {
+		e.clearMark()
+		e.setStatus("Mark cleared", 1)
+		return
+	}
+	pos := e.cursorPosition()
+	e.mark = &pos
+	e.setStatus("Mark set", 1)
+}
+
+func (e *Editor) clearMark() {
+	e.mark = nil
+}
+
+// markedLines returns the rows between the mark and the cursor
+func (e *Editor) markedLines() (lineRange, bool) {
+	if e.mark == nil {
+		return lineRange{}, false
+	}
+	last := e.document.lineCount() - 1
+	start := min(e.mark.row, e.cursor.row, last)
+	end := min(max(e.mark.row, e.cursor.row), last)
+	return lineRange{start, end}, true
+}
+
+// selectedLines returns the marked lines, or the cursor line if nothing is marked
+func (e *Editor) selectedLines() lineRange {
+	if r, ok := e.markedLines(); ok {
+		return r
+	}
+	return lineRange{e.cursor.row, e.cursor.row}
+}
+
+func (e *Editor) handleIndentLines() {
+	r := e.selectedLines()
+	e.shiftLines(r, e.document.indentLines)
+	e.setStatus(fmt.Sprintf("indented %d lines", r.count()), 1)
+}
+
+func (e *Editor) handleDedentLines() {
+	r := e.selectedLines()
+	e.shiftLines(r, e.document.dedentLines)
+	e.setStatus(fmt.Sprintf("dedented %d lines", r.count()), 1)
+}
+
+// shiftLines applies an indentation change to a range of lines, keeping
+// the cursor at the same place relative to the text of its line
+func (e *Editor) shiftLines(r lineRange, shift func(start, end int) error) {
+	before := e.currentLineLength()
+	err := shift(r.start, r.end)
+	if e.handleError("failed to change indentation", err) {
+		return
+	}
+	col := max(e.cursor.col+e.currentLineLength()-before, 0)
+	e.cursor.col = col
+	e.cursor.anchor = col
+	e.setDirty()
+}
+
+func (e *Editor) handleMoveLinesUp() {
+	r := e.selectedLines()
+	if r.start == 0 {
+		return
+	}
+	content, err := e.document.getLine(r.start - 1)
+	if e.handleError("failed to move lines", err) {
+		return
+	}
+	err = e.document.removeLine(r.start - 1)
+	if e.handleError("failed to move lines", err) {
+		return
+	}
+	err = e.document.addLine(r.end, content)
+	if e.handleError("failed to move lines", err) {
+		return
+	}
+	e.shiftSelection(-1)
+	e.setDirty()
+}
+
+func (e *Editor) handleMoveLinesDown() {
+	r := e.selectedLines()
+	if r.end >= e.document.lineCount()-1 {
+		return
+	}
+	content, err := e.document.getLine(r.end + 1)
+	if e.handleError("failed to move lines", err) {
+		return
+	}
+	err = e.document.removeLine(r.end + 1)
+	if e.handleError("failed to move lines", err) {
+		return
+	}
+	err = e.document.addLine(r.start, content)
+	if e.handleError("failed to move lines", err) {
+		return
+	}
+	e.shiftSelection(1)
+	e.setDirty()
+}
+
+// handleDuplicateLines inserts a copy of the line or marked lines below
+// them and moves the cursor onto the copy
+func (e *Editor) handleDuplicateLines() {
+	r := e.selectedLines()
+	for i := range r.count() {
+		content, err := e.document.getLine(r.start + i)
+		if e.handleError("failed to duplicate lines", err) {
+			return
+		}
+		err = e.document.addLine(r.end+1+i, content)
+		if e.handleError("failed to duplicate lines", err) {
+			return
+		}
+	}
+	e.shiftSelection(r.count())
+	e.setDirty()
+}
+
+// handleJoinLines merges the next line into the current one, or all
+// marked lines into one, separated by a single space
+func (e *Editor) handleJoinLines() {
+	r := e.selectedLines()
+	joins := max(r.count()-1, 1)
+	if r.start+joins >= e.document.lineCount() {
+		return
+	}
+	var row, col int
+	for range joins {
+		var err error
+		row, col, err = e.joinLines(r.start)
+		if e.handleError("failed to join lines", err) {
+			return
+		}
+	}
+	e.clearMark()
+	e.cursor.moveTo(row, col)
+	e.cursor.anchor = col
+	e.setDirty()
+}
+
+// joinLines merges row+1 into row, replacing the whitespace around the
+// line break with a single space, and returns the position of the join
+func (e *Editor) joinLines(row int) (int, int, error) {
+	current, err := e.document.getLine(row)
+	if err != nil {
+		return 0, 0, err
+	}
+	next, err := e.document.getLine(row + 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	current = strings.TrimRightFunc(current, unicode.IsSpace)
+	next = strings.TrimLeftFunc(next, unicode.IsSpace)
+	if current != "" && next != "" {
+		current += " "
+	}
+	err = e.document.replaceLine(row, current)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = e.document.replaceLine(row+1, next)
+	if err != nil {
+		return 0, 0, err
+	}
+	return e.document.mergeLines(row + 1)
+}
+
+// shiftSelection moves the cursor and the mark by delta rows
+func (e *Editor) shiftSelection(delta int) {
+	e.cursor.row += delta
+	if e.mark != nil {
+		e.mark.row += delta
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJoinLines(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+		col  int
+	}{
+		{"single space", "foo\nbar", []string{"foo bar"}, 4},
+		{"trims around the break", "foo  \n\t  bar\nbaz", []string{"foo bar", "baz"}, 4},
+		{"empty next line", "foo\n\nbar", []string{"foo", "bar"}, 3},
+		{"empty current line", "\n  bar", []string{"bar"}, 0},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "", tt.text)
+		row, col, err := e.joinLines(0)
+		if err != nil || !slices.Equal(docLines(e.document), tt.want) || row != 0 || col != tt.col {
+			t.Errorf("%s: got %q at %d:%d, %v, want %q at 0:%d", tt.name, docLines(e.document), row, col, err, tt.want, tt.col)
+		}
+	}
+}
+
+func TestLineCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(e *Editor)
+		// cursor row and mark row, -1 for no mark
+		cursor, mark int
+		want         []string
+		wantCursor   int
+		wantMark     int
+	}{
+		{"move up", (*Editor).handleMoveLinesUp, 1, -1, []string{"b", "a", "c", "d"}, 0, -1},
+		{"move up at the top", (*Editor).handleMoveLinesUp, 0, -1, []string{"a", "b", "c", "d"}, 0, -1},
+		{"move marked lines up", (*Editor).handleMoveLinesUp, 2, 1, []string{"b", "c", "a", "d"}, 1, 0},
+		{"move down", (*Editor).handleMoveLinesDown, 1, -1, []string{"a", "c", "b", "d"}, 2, -1},
+		{"move down at the bottom", (*Editor).handleMoveLinesDown, 3, -1, []string{"a", "b", "c", "d"}, 3, -1},
+		{"move marked lines down", (*Editor).handleMoveLinesDown, 1, 2, []string{"a", "d", "b", "c"}, 2, 3},
+		{"duplicate", (*Editor).handleDuplicateLines, 1, -1, []string{"a", "b", "b", "c", "d"}, 2, -1},
+		{"duplicate marked lines", (*Editor).handleDuplicateLines, 0, 1, []string{"a", "b", "a", "b", "c", "d"}, 2, 3},
+		{"join", (*Editor).handleJoinLines, 1, -1, []string{"a", "b c", "d"}, 1, -1},
+		{"join marked lines", (*Editor).handleJoinLines, 3, 1, []string{"a", "b c d"}, 1, -1},
+		{"join the last line", (*Editor).handleJoinLines, 3, -1, []string{"a", "b", "c", "d"}, 3, -1},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "", "a\nb\nc\nd")
+		e.cursor.moveTo(tt.cursor, 0)
+		if tt.mark >= 0 {
+			e.mark = &position{row: tt.mark}
+		}
+		tt.action(e)
+		mark := -1
+		if e.mark != nil {
+			mark = e.mark.row
+		}
+		if got := docLines(e.document); !slices.Equal(got, tt.want) || e.cursor.row != tt.wantCursor || mark != tt.wantMark {
+			t.Errorf("%s: got %q, cursor %d, mark %d, want %q, cursor %d, mark %d", tt.name, got, e.cursor.row, mark, tt.want, tt.wantCursor, tt.wantMark)
+		}
+	}
+}
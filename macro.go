@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_MACRO is the register used when no macro name is given
+const DEFAULT_MACRO = "last"
+
+const (
+	ErrUnknownMacro     = gtextError("no such macro")
+	ErrInvalidMacroName = gtextError("invalid macro name")
+	ErrMacroPlaying     = gtextError("cannot play a macro from inside a macro")
+	ErrMacroJob         = gtextError("macro stopped at a shell command")
+	ErrMacroFile        = gtextError("invalid macros file")
+	ErrKeyInUse         = gtextError("key is already bound")
+)
+
+// Macros holds the named keyboard macros and the state of recording.
+// Macros are the key events seen by processKeyPress, stored in the same
+// form they arrive from the terminal.
+type Macros struct {
+	registers map[string][]rune
+	order     []string
+	bindings  map[rune]string
+	path      string
+
+	recording string // register being recorded, or "" when not recording
+	keys      []rune
+	mark      int // number of recorded keys before the latest edit mode key
+	playing   bool
+}
+
+func NewMacros(path string) *Macros {
+	return &Macros{
+		registers: make(map[string][]rune),
+		bindings:  make(map[rune]string),
+		path:      path,
+	}
+}
+
+// macrosPath returns ~/.config/gtext/macros
+func macrosPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gtext", "macros")
+}
+
+func validMacroName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !isWordRune(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Macros) set(name string, keys []rune) {
+	if _, exists := m.registers[name]; !exists {
+		m.order = append(m.order, name)
+	}
+	m.registers[name] = keys
+}
+
+// capture records a key while recording. Keys that arrive in edit mode
+// start a new step, so that the keys which stop recording, whether a
+// single binding or a command typed on the command line, can be dropped.
+func (m *Macros) capture(r rune, editMode bool) {
+	if m.recording == "" || m.playing {
+		return
+	}
+	if editMode {
+		m.mark = len(m.keys)
+	}
+	m.keys = append(m.keys, r)
+}
+
+func (m *Macros) start(name string) {
+	m.recording = name
+	m.keys = nil
+	m.mark = 0
+}
+
+// stop ends recording and stores the keys recorded before the current step
+func (m *Macros) stop() (string, int) {
+	name := m.recording
+	keys := m.keys[:m.mark]
+	m.recording = ""
+	m.keys = nil
+	m.set(name, keys)
+	return name, len(keys)
+}
+
+// Load reads macros and key bindings. Each line is either
+//
+//	macro NAME = KEY KEY ...
+//	bind KEY = NAME
+//
+// where keys are written as shown in the key bindings, e.g. Ctrl-Right or Enter.
+func (m *Macros) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := m.parseLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (m *Macros) parseLine(line string) error {
+	head, value, ok := strings.Cut(line, "=")
+	kind, name, _ := strings.Cut(strings.TrimSpace(head), " ")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if !ok || name == "" {
+		return fmt.Errorf("%w: expected 'macro NAME = KEYS' or 'bind KEY = NAME'", ErrMacroFile)
+	}
+	switch kind {
+	case "macro":
+		if !validMacroName(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidMacroName, name)
+		}
+		var keys []rune
+		for _, field := range strings.Fields(value) {
+			r, err := parseKeyName(field)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, r)
+		}
+		m.set(name, keys)
+	case "bind":
+		key, err := parseKeyName(name)
+		if err != nil {
+			return err
+		}
+		if !validMacroName(value) {
+			return fmt.Errorf("%w: %q", ErrInvalidMacroName, value)
+		}
+		m.bindings[key] = value
+	default:
+		return fmt.Errorf("%w: unknown entry %q", ErrMacroFile, kind)
+	}
+	return nil
+}
+
+// Save writes all macros and bindings in the format read by Load
+func (m *Macros) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# gtext keyboard macros")
+	for _, name := range m.order {
+		names := make([]string, 0, len(m.registers[name]))
+		for _, r := range m.registers[name] {
+			names = append(names, keyName(r))
+		}
+		fmt.Fprintf(bw, "macro %s = %s\n", name, strings.Join(names, " "))
+	}
+	keys := slices.Sorted(maps.Keys(m.bindings))
+	for _, key := range keys {
+		fmt.Fprintf(bw, "bind %s = %s\n", keyName(key), m.bindings[key])
+	}
+	return bw.Flush()
+}
+
+func (m *Macros) LoadFromDisk() error {
+	if m.path == "" {
+		return nil
+	}
+	file, err := os.Open(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = m.Load(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.path, err)
+	}
+	return nil
+}
+
+func (m *Macros) SaveToDisk() error {
+	if m.path == "" {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(m.path), 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(m.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return m.Save(file)
+}
+
+// loadMacros reads the saved macros and binds their keys
+func (e *Editor) loadMacros() {
+	err := e.macros.LoadFromDisk()
+	if err != nil {
+		e.reportError(fmt.Errorf("could not load macros: %w", err))
+	}
+	for key, name := range e.macros.bindings {
+		e.reportError(e.bindMacro(key, name))
+	}
+}
+
+// bindMacro binds a key to play a macro, refusing keys used by other commands
+func (e *Editor) bindMacro(key rune, name string) error {
+	bound, ok := e.commands.bindings[key]
+	if ok && !strings.HasPrefix(bound, "macro-") {
+		return fmt.Errorf("%w: %s runs %s", ErrKeyInUse, keyName(key), bound)
+	}
+	if ok {
+		// take the key away from the macro it played before
+		old := e.commands.cmds[bound]
+		old.key = 0
+		e.commands.register(old)
+	}
+	e.commands.register(Command{
+		name: "macro-" + name,
+		key:  key,
+		desc: "Play macro " + name,
+		action: func() {
+			e.reportError(e.playMacro(name, 1))
+		},
+	})
+	return nil
+}
+
+func (e *Editor) handleRecordMacro() {
+	e.reportError(e.toggleRecording(DEFAULT_MACRO))
+}
+
+func (e *Editor) handlePlayMacro() {
+	e.reportError(e.playMacro(DEFAULT_MACRO, 1))
+}
+
+// toggleRecording starts recording into a register, or stops the
+// recording in progress and saves it
+func (e *Editor) toggleRecording(name string) error {
+	if e.macros.recording != "" {
+		name, n := e.macros.stop()
+		e.setStatus(fmt.Sprintf("recorded %d keys into macro %s", n, name), 2)
+		return e.macros.SaveToDisk()
+	}
+	if !validMacroName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidMacroName, name)
+	}
+	e.macros.start(name)
+	e.setStatus(fmt.Sprintf("recording macro %s", name), 2)
+	return nil
+}
+
+// playMacro feeds the keys of a macro through processKeyPress count times
+func (e *Editor) playMacro(name string, count int) error {
+	keys, ok := e.macros.registers[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMacro, name)
+	}
+	if e.macros.playing {
+		return ErrMacroPlaying
+	}
+	e.macros.playing = true
+	defer func() { e.macros.playing = false }()
+	for range count {
+		for _, r := range keys {
+			e.processKeyPress(r)
+			if e.shuttingDown() {
+				return nil
+			}
+			// the remaining keys would only reach the running command
+			if e.job != nil {
+				return fmt.Errorf("%w: %s", ErrMacroJob, e.job.command)
+			}
+		}
+	}
+	e.setStatus(fmt.Sprintf("played macro %s %d times", name, count), 2)
+	return nil
+}
+
+func (e *Editor) execRecord(args cmdArgs) error {
+	name := DEFAULT_MACRO
+	if args.has("macro") {
+		name = args.str("macro")
+	}
+	return e.toggleRecording(name)
+}
+
+// execPlay plays a macro, accepting a count alone for the default macro
+func (e *Editor) execPlay(args cmdArgs) error {
+	name, count := DEFAULT_MACRO, 1
+	if args.has("macro") {
+		name = args.str("macro")
+	}
+	if args.has("count") {
+		count = args.num("count")
+	} else if n, err := strconv.Atoi(name); err == nil {
+		name, count = DEFAULT_MACRO, n
+	}
+	if count < 1 {
+		return fmt.Errorf("%w count: must be at least 1", ErrInvalidArg)
+	}
+	return e.playMacro(name, count)
+}
+
+func (e *Editor) execBindMacro(args cmdArgs) error {
+	key, err := parseKeyName(args.str("key"))
+	if err != nil {
+		return err
+	}
+	name := DEFAULT_MACRO
+	if args.has("macro") {
+		name = args.str("macro")
+	}
+	if _, ok := e.macros.registers[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMacro, name)
+	}
+	err = e.bindMacro(key, name)
+	if err != nil {
+		return err
+	}
+	// a macro has at most one key, like any other command
+	maps.DeleteFunc(e.macros.bindings, func(_ rune, bound string) bool {
+		return bound == name
+	})
+	e.macros.bindings[key] = name
+	e.setStatus(fmt.Sprintf("%s plays macro %s", keyName(key), name), 2)
+	return e.macros.SaveToDisk()
+}
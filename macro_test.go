@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMacrosSaveLoad(t *testing.T) {
+	m := NewMacros("")
+	m.set("greet", []rune{'h', 'i', RETURN, CTRL | ARROW_RIGHT})
+	m.set("blank", nil)
+	m.bindings[ALT|'g'] = "greet"
+
+	var out strings.Builder
+	err := m.Save(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# gtext keyboard macros\n" +
+		"macro greet = h i Enter Ctrl-Right\n" +
+		"macro blank = \n" +
+		"bind Alt-g = greet\n"
+	if out.String() != want {
+		t.Errorf("Save() = %q, want %q", out.String(), want)
+	}
+
+	loaded := NewMacros("")
+	err = loaded.Load(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(loaded.order, m.order) {
+		t.Errorf("order = %v, want %v", loaded.order, m.order)
+	}
+	for _, name := range m.order {
+		if !slices.Equal(loaded.registers[name], m.registers[name]) {
+			t.Errorf("macro %s = %v, want %v", name, loaded.registers[name], m.registers[name])
+		}
+	}
+	if loaded.bindings[ALT|'g'] != "greet" {
+		t.Errorf("binding for Alt-g = %q, want greet", loaded.bindings[ALT|'g'])
+	}
+}
+
+func TestMacrosLoadErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+	}{
+		{"macro x", ErrMacroFile},
+		{"record x = a", ErrMacroFile},
+		{"macro bad name = a", ErrInvalidMacroName},
+		{"macro x = Nope", ErrUnknownKey},
+		{"bind Nope = x", ErrUnknownKey},
+		{"bind Alt-x = a/b", ErrInvalidMacroName},
+	}
+	for _, tt := range tests {
+		err := NewMacros("").Load(strings.NewReader("# comment\n\n" + tt.input))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Load(%q) error = %v, want %v", tt.input, err, tt.want)
+		}
+		if err != nil && !strings.HasPrefix(err.Error(), "line 3: ") {
+			t.Errorf("Load(%q) error = %q, want the line number", tt.input, err)
+		}
+	}
+}
+
+func TestMacrosSetKeepsOrder(t *testing.T) {
+	m := NewMacros("")
+	m.set("a", []rune{'1'})
+	m.set("b", []rune{'2'})
+	m.set("a", []rune{'3'})
+	if !slices.Equal(m.order, []string{"a", "b"}) {
+		t.Errorf("order = %v, want [a b]", m.order)
+	}
+	if !slices.Equal(m.registers["a"], []rune{'3'}) {
+		t.Errorf("macro a = %v, want [3]", m.registers["a"])
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -11,28 +12,65 @@ func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: gtext [flags] <filename> | [command]\n\n")
 		fmt.Fprintln(os.Stderr, "Commands:")
-		fmt.Fprintln(os.Stderr, "  config\tInitializes or prints editor configuration.")
+		fmt.Fprintln(os.Stderr, "  config\tInitializes the configuration interactively.")
+		fmt.Fprintln(os.Stderr, "  config check [file]\n\t\tReports problems in the config files used for file.")
+		fmt.Fprintln(os.Stderr, "  config print [file]\n\t\tPrints the effective settings for file and where they come from.")
+		fmt.Fprintln(os.Stderr, "  config defaults\n\t\tPrints every setting with its default and description.")
 		fmt.Fprintln(os.Stderr, "  help\t\tPrints this help message.")
 		fmt.Fprintln(os.Stderr, "  <filename>\tOpens the specified file for editing.")
+		fmt.Fprintln(os.Stderr, "  <filename>:<line>[:<col>]\n\t\tOpens the file at the given position.")
 		fmt.Fprintln(os.Stderr, "\nOptions:")
 		flag.PrintDefaults()
 	}
 
+	var cli []setting
+	defineSettingFlags(flag.CommandLine, &cli)
+	flag.Func("set", "Override any setting with `key=value`, e.g. --set formatter.go=gofmt (repeatable)", func(arg string) error {
+		key, val, _ := strings.Cut(arg, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		err := checkSetting(key, val)
+		if err != nil {
+			return err
+		}
+		cli = append(cli, setting{key: key, value: val, origin: "--set"})
+		return nil
+	})
+	readonly := flag.Bool("readonly", false, "Open files read-only")
+	line := flag.Int("line", 0, "Open the file at line `N`")
+	configPath := flag.String("config", "", "Read the user config from `path` instead of ~/"+CONFIGFILE)
+	noConfig := flag.Bool("no-config", false, "Ignore all config files, including .editorconfig")
+	version := flag.Bool("version", false, "Print the version and exit")
+
 	flag.Parse()
 
+	if *version {
+		fmt.Printf("gtext v%s\n", VERSION)
+		return
+	}
+	if *configPath != "" && !*noConfig {
+		if _, err := os.Stat(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	layers := loadConfigLayers(*configPath, *noConfig)
+	layers.cli = cli
+
+	location := ""
+	if *line > 0 {
+		location = fmt.Sprintf("%d", *line)
+	}
+
 	args := flag.Args()
 
 	if len(args) == 0 {
-		defaultFile := "untitled.txt"
-		fmt.Printf("No file provided, starting new document: %s\n", defaultFile)
-		exitCode := Run(defaultFile)
+		exitCode := Run("", location, layers, *readonly)
 		os.Exit(exitCode)
 	}
 
 	switch args[0] {
 	case "config":
-		initConfig()
-		return
+		os.Exit(runConfig(args[1:], layers))
 	case "help":
 		flag.Usage()
 		return
@@ -44,7 +82,16 @@ func main() {
 		}
 
 		filename := args[0]
-		exitCode := Run(filename)
+		if _, err := os.Stat(filename); err != nil {
+			if name, row, col := splitFileLocation(filename); row > 0 {
+				// --line only overrides the row
+				if *line > 0 {
+					row = *line
+				}
+				filename, location = name, fmt.Sprintf("%d:%d", row, max(col, 1))
+			}
+		}
+		exitCode := Run(filename, location, layers, *readonly)
 		os.Exit(exitCode)
 	}
 }
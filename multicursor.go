@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// textChange describes an edit as the span [start, oldEnd) of the old
+// text being replaced by new text that ends at newEnd
+type textChange struct {
+	start, oldEnd, newEnd position
+}
+
+func comparePositions(a, b position) int {
+	if a.row != b.row {
+		return a.row - b.row
+	}
+	return a.col - b.col
+}
+
+// shift maps a position in the old text to the new text
+func (c textChange) shift(p position) position {
+	if comparePositions(p, c.start) < 0 {
+		return p
+	}
+	if comparePositions(p, c.oldEnd) < 0 {
+		return c.newEnd
+	}
+	if p.row == c.oldEnd.row {
+		return position{c.newEnd.row, c.newEnd.col + p.col - c.oldEnd.col}
+	}
+	return position{p.row + c.newEnd.row - c.oldEnd.row, p.col}
+}
+
+// editSpan is the block of rows changed by a sequence of edits: the rows
+// from start as they were before, and how many rows replaced them
+type editSpan struct {
+	start    int
+	old      []string
+	newCount int
+}
+
+// recordEdit adds the replacement of rows [start, end) by newCount rows to
+// the edit being collected, if any. It must be called before the change.
+func (d *Document) recordEdit(start, end, newCount int) {
+	if d.edit == nil {
+		return
+	}
+	span := d.edit
+	if span.old == nil {
+		span.start = start
+		span.old = d.rowContents(start, end)
+		span.newCount = newCount
+		return
+	}
+	// rows between the span and the edit are unchanged, so their current
+	// contents are also their contents before
+	spanEnd := span.start + span.newCount
+	first, last := min(span.start, start), max(spanEnd, end)
+	old := d.rowContents(first, span.start)
+	old = append(old, span.old...)
+	old = append(old, d.rowContents(spanEnd, last)...)
+	span.newCount = last - first - (end - start) + newCount
+	span.start = first
+	span.old = old
+}
+
+// rowContents returns the contents of rows [start, end), empty if end
+// is not past start
+func (d *Document) rowContents(start, end int) []string {
+	contents := []string{}
+	for row := start; row < end; row++ {
+		contents = append(contents, d.lines[row].content)
+	}
+	return contents
+}
+
+// changes describes the edits of a span as text changes, in document order
+func (d *Document) changes(span *editSpan) []textChange {
+	var changes []textChange
+	for _, h := range diffHunks(span.old, d.rowContents(span.start, span.start+span.newCount)) {
+		changes = append(changes, hunkChange(span.start+h.start, span.old[h.start:h.end], h.lines))
+	}
+	return changes
+}
+
+// hunkChange narrows the replacement of the old lines at row by the new
+// ones down to the runes that differ
+func hunkChange(row int, before, after []string) textChange {
+	oldText := []rune(strings.Join(before, "\n"))
+	newText := []rune(strings.Join(after, "\n"))
+	// a whole line inserted or removed between unchanged lines
+	if len(before) == 0 || len(after) == 0 {
+		return lineChange(row, len(before), len(after))
+	}
+	prefix := 0
+	for prefix < len(oldText) && prefix < len(newText) && oldText[prefix] == newText[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldText)-prefix && suffix < len(newText)-prefix &&
+		oldText[len(oldText)-1-suffix] == newText[len(newText)-1-suffix] {
+		suffix++
+	}
+	return textChange{
+		start:  offsetPosition(row, oldText, prefix),
+		oldEnd: offsetPosition(row, oldText, len(oldText)-suffix),
+		newEnd: offsetPosition(row, newText, len(newText)-suffix),
+	}
+}
+
+// lineChange describes replacing oldCount whole lines at row by newCount lines
+func lineChange(row, oldCount, newCount int) textChange {
+	return textChange{
+		start:  position{row, 0},
+		oldEnd: position{row + oldCount, 0},
+		newEnd: position{row + newCount, 0},
+	}
+}
+
+// offsetPosition converts a rune offset into text starting at row
+// to a document position
+func offsetPosition(row int, text []rune, offset int) position {
+	col := 0
+	for _, r := range text[:offset] {
+		if r == '\n' {
+			row++
+			col = 0
+		} else {
+			col++
+		}
+	}
+	return position{row, col}
+}
+
+func (d *Document) contents() []string {
+	contents := make([]string, len(d.lines))
+	for i, l := range d.lines {
+		contents[i] = l.content
+	}
+	return contents
+}
+
+func (e *Editor) hasExtraCursors() bool {
+	return len(e.extraCursors) > 0
+}
+
+func (e *Editor) clearExtraCursors() {
+	e.extraCursors = nil
+}
+
+// allCursors returns the primary and extra cursors in document order
+func (e *Editor) allCursors() []*Cursor {
+	cursors := append([]*Cursor{e.cursor}, e.extraCursors...)
+	slices.SortStableFunc(cursors, func(a, b *Cursor) int {
+		return comparePositions(position{a.row, a.col}, position{b.row, b.col})
+	})
+	return cursors
+}
+
+// forEachCursor runs action once at every cursor, moving the other cursors
+// along with any text the action inserts or deletes, then merges cursors
+// that ended up in the same place
+func (e *Editor) forEachCursor(action func()) {
+	primary := e.cursor
+	cursors := e.allCursors()
+	for i, c := range cursors {
+		span := &editSpan{}
+		e.document.edit = span
+		e.cursor = c
+		action()
+		e.document.edit = nil
+		if span.old == nil {
+			continue
+		}
+		// apply the later changes first, so positions are still in the old
+		// rows when the earlier ones shift them
+		changes := e.document.changes(span)
+		for j, other := range cursors {
+			if j == i {
+				continue
+			}
+			p := position{other.row, other.col}
+			for k := len(changes) - 1; k >= 0; k-- {
+				p = changes[k].shift(p)
+			}
+			other.moveTo(p.row, p.col)
+			other.anchor = p.col
+		}
+	}
+	e.cursor = primary
+	e.mergeCursors()
+}
+
+// mergeCursors drops extra cursors that share a position with another cursor
+func (e *Editor) mergeCursors() {
+	seen := map[position]bool{{e.cursor.row, e.cursor.col}: true}
+	kept := e.extraCursors[:0]
+	for _, c := range e.extraCursors {
+		p := position{c.row, c.col}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		kept = append(kept, c)
+	}
+	e.extraCursors = kept
+}
+
+// addCursor adds an extra cursor at pos unless one is already there
+func (e *Editor) addCursor(pos position) bool {
+	for _, c := range e.allCursors() {
+		if c.row == pos.row && c.col == pos.col {
+			return false
+		}
+	}
+	c := NewCursor(pos.row, pos.col)
+	c.anchor = pos.col
+	e.extraCursors = append(e.extraCursors, c)
+	e.lastCursor = pos
+	return true
+}
+
+func (e *Editor) handleAddCursorAbove() {
+	top := e.allCursors()[0]
+	if top.row == 0 {
+		return
+	}
+	e.addCursorOnRow(top.row - 1)
+}
+
+func (e *Editor) handleAddCursorBelow() {
+	cursors := e.allCursors()
+	bottom := cursors[len(cursors)-1]
+	if bottom.row >= e.document.lineCount()-1 {
+		return
+	}
+	e.addCursorOnRow(bottom.row + 1)
+}
+
+// addCursorOnRow adds a cursor at the primary cursor's column, or at the
+// end of the row if it is shorter
+func (e *Editor) addCursorOnRow(row int) {
+	col := min(e.cursor.anchor, e.document.getLineLength(row))
+	e.addCursor(position{row, col})
+}
+
+// wordAtCursor returns the word under or just before the cursor and the
+// cursor's offset into it
+func (e *Editor) wordAtCursor() (string, int, bool) {
+	content, err := e.document.getLine(e.cursor.row)
+	if err != nil {
+		return "", 0, false
+	}
+	runes := []rune(content)
+	start := e.cursor.col
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	end := e.cursor.col
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+	if start == end {
+		return "", 0, false
+	}
+	return string(runes[start:end]), e.cursor.col - start, true
+}
+
+// wordMatches finds whole word occurrences of the word under the cursor,
+// returning cursor positions at the same offset into each occurrence
+func (e *Editor) wordMatches() ([]position, bool) {
+	word, offset, ok := e.wordAtCursor()
+	if !ok {
+		return nil, false
+	}
+	finder := &Finder{findString: word}
+	finder.find(e.document)
+
+	var positions []position
+	for _, m := range finder.matches {
+		content := e.document.lines[m.row].content
+		before, _ := utf8.DecodeLastRuneInString(content[:m.col])
+		after, _ := utf8.DecodeRuneInString(content[m.col+len(word):])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		col := utf8.RuneCountInString(content[:m.col])
+		positions = append(positions, position{m.row, col + offset})
+	}
+	return positions, true
+}
+
+// handleAddCursorAtNextMatch adds a cursor at the next occurrence of the
+// word under the cursor, after the most recently added cursor
+func (e *Editor) handleAddCursorAtNextMatch() {
+	positions, ok := e.wordMatches()
+	if !ok {
+		e.setStatus("No word at cursor", 1)
+		return
+	}
+	from := e.cursorPosition()
+	if e.hasExtraCursors() {
+		from = e.lastCursor
+	}
+	idx := slices.IndexFunc(positions, func(p position) bool {
+		return comparePositions(p, from) > 0
+	})
+	if idx == -1 {
+		idx = 0
+	}
+	for i := range positions {
+		p := positions[(idx+i)%len(positions)]
+		if e.addCursor(p) {
+			e.setStatus(fmt.Sprintf("%d cursors", len(e.extraCursors)+1), 1)
+			return
+		}
+	}
+	e.setStatus("No more matches", 1)
+}
+
+// handleAddCursorsAtAllMatches adds a cursor at every occurrence of the
+// word under the cursor
+func (e *Editor) handleAddCursorsAtAllMatches() {
+	positions, ok := e.wordMatches()
+	if !ok {
+		e.setStatus("No word at cursor", 1)
+		return
+	}
+	for _, p := range positions {
+		e.addCursor(p)
+	}
+	e.setStatus(fmt.Sprintf("%d cursors", len(e.extraCursors)+1), 1)
+}
+
+// extraCursorPositions returns the extra cursors for rendering
+func (e *Editor) extraCursorPositions() []position {
+	positions := make([]position, 0, len(e.extraCursors))
+	for _, c := range e.extraCursors {
+		positions = append(positions, position{c.row, c.col})
+	}
+	return positions
+}
@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestEditChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(d *Document) error
+		// a cursor somewhere else in the document and where the edit moves it
+		from, want position
+	}{
+		{
+			name: "insert before on the same line",
+			edit: func(d *Document) error { return d.insertRune(1, 1, 'x') },
+			from: position{1, 3}, want: position{1, 4},
+		},
+		{
+			name: "insert after on the same line",
+			edit: func(d *Document) error { return d.insertRune(1, 3, 'x') },
+			from: position{1, 1}, want: position{1, 1},
+		},
+		{
+			name: "split the line before",
+			edit: func(d *Document) error {
+				_, _, err := d.insertNewLine(1, 2)
+				return err
+			},
+			from: position{1, 3}, want: position{2, 1},
+		},
+		{
+			name: "new line above",
+			edit: func(d *Document) error { return d.addLine(0, "new") },
+			from: position{2, 2}, want: position{3, 2},
+		},
+		{
+			name: "join with the line above",
+			edit: func(d *Document) error {
+				_, _, err := d.mergeLines(1)
+				return err
+			},
+			from: position{1, 2}, want: position{0, 7},
+		},
+		{
+			name: "delete a line above",
+			edit: func(d *Document) error { return d.removeLine(0) },
+			from: position{2, 1}, want: position{1, 1},
+		},
+		{
+			name: "several rows at once",
+			edit: func(d *Document) error {
+				err := d.replaceLine(2, "CCCC")
+				if err == nil {
+					err = d.insertRune(0, 0, '>')
+				}
+				return err
+			},
+			from: position{1, 2}, want: position{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newTestDocument(t, "", "first\nsecond\nthird\n")
+			span := &editSpan{}
+			doc.edit = span
+			err := tt.edit(doc)
+			doc.edit = nil
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := doc.changes(span)
+			p := tt.from
+			for i := len(changes) - 1; i >= 0; i-- {
+				p = changes[i].shift(p)
+			}
+			if p != tt.want {
+				t.Errorf("cursor at %v moved to %v, want %v (changes %v)", tt.from, p, tt.want, changes)
+			}
+		})
+	}
+}
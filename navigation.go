@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (e *Editor) handleGoto() {
+	e.openPrompt("Go to line[:col], +/-N or N%: ", "", "goto", nil, func(spec string) {
+		e.reportError(e.gotoLocation(spec))
+	})
+}
+
+// gotoLocation jumps to a location as understood by parseLocation,
+// remembering the current position in the jump list
+func (e *Editor) gotoLocation(spec string) error {
+	pos, err := parseLocation(spec, e.cursorPosition(), e.document.lineCount())
+	if err != nil {
+		return err
+	}
+	e.recordJump()
+	e.gotoPosition(pos)
+	return nil
+}
+
+// gotoPosition moves the cursor to pos, clamped to the document,
+// and centres the view on it
+func (e *Editor) gotoPosition(pos position) {
+	row := min(max(pos.row, 0), e.document.lineCount()-1)
+	col := min(max(pos.col, 0), e.document.getLineLength(row))
+	e.cursor.moveTo(row, col)
+	e.cursor.anchor = col
+	e.view.centerOn(row, e.document.lineCount())
+}
+
+func (e *Editor) cursorPosition() position {
+	return position{row: e.cursor.row, col: e.cursor.col}
+}
+
+func (e *Editor) currentJump() jump {
+	return jump{fileName: e.document.fileName, pos: e.cursorPosition()}
+}
+
+// recordJump remembers the cursor position before a large jump
+func (e *Editor) recordJump() {
+	e.jumps.record(e.currentJump())
+}
+
+func (e *Editor) handleJumpBack() {
+	target, ok := e.jumps.back(e.currentJump())
+	if !ok {
+		e.setStatus("No older jump", 1)
+		return
+	}
+	e.jumpTo(target)
+}
+
+func (e *Editor) handleJumpForward() {
+	target, ok := e.jumps.forward()
+	if !ok {
+		e.setStatus("No newer jump", 1)
+		return
+	}
+	e.jumpTo(target)
+}
+
+// jumpTo moves to a jump list entry, switching files if needed
+func (e *Editor) jumpTo(target jump) {
+	if target.fileName != e.document.fileName {
+		err := e.openFile(target.fileName)
+		if err != nil {
+			e.reportError(fmt.Errorf("could not return to %s: %w", target.fileName, err))
+			return
+		}
+	}
+	e.gotoPosition(target.pos)
+}
+
+// moveWordLeft moves to the start of the previous word, or to the end of
+// the previous line when at the start of a line
+func (e *Editor) moveWordLeft() {
+	row, col := e.cursor.coords()
+	if col == 0 {
+		e.moveLeft()
+		return
+	}
+	content, err := e.document.getLine(row)
+	if e.handleError("could not read current line", err) {
+		return
+	}
+	e.cursor.col = wordStartBefore([]rune(content), col)
+	e.cursor.anchor = e.cursor.col
+}
+
+// moveWordRight moves past the end of the next word, or to the start of
+// the next line when at the end of a line
+func (e *Editor) moveWordRight() {
+	row, col := e.cursor.coords()
+	if col >= e.currentLineLength() {
+		if row < e.document.lineCount()-1 {
+			e.cursor.moveTo(row+1, 0)
+			e.cursor.anchor = 0
+		}
+		return
+	}
+	content, err := e.document.getLine(row)
+	if e.handleError("could not read current line", err) {
+		return
+	}
+	e.cursor.col = wordEndAfter([]rune(content), col)
+	e.cursor.anchor = e.cursor.col
+}
+
+// moveParagraphUp moves to the blank line before the current paragraph
+func (e *Editor) moveParagraphUp() {
+	row := e.cursor.row
+	for row > 0 && e.isBlankLine(row) {
+		row--
+	}
+	for row > 0 && !e.isBlankLine(row) {
+		row--
+	}
+	e.cursor.moveTo(max(row, 0), 0)
+	e.cursor.anchor = 0
+}
+
+// moveParagraphDown moves to the blank line after the current paragraph
+func (e *Editor) moveParagraphDown() {
+	last := e.document.lineCount() - 1
+	row := e.cursor.row
+	for row < last && e.isBlankLine(row) {
+		row++
+	}
+	for row < last && !e.isBlankLine(row) {
+		row++
+	}
+	e.cursor.moveTo(min(row, last), 0)
+	e.cursor.anchor = 0
+}
+
+func (e *Editor) isBlankLine(row int) bool {
+	content, err := e.document.getLine(row)
+	return err == nil && isBlank(content)
+}
+
+// handleMatchBracket jumps to the bracket matching the one at the cursor
+func (e *Editor) handleMatchBracket() {
+	pos, ok := e.document.bracketAt(e.cursor.row, e.cursor.col)
+	if !ok {
+		e.setStatus("No bracket at cursor", 1)
+		return
+	}
+	match, ok := e.document.matchBracket(pos)
+	if !ok {
+		e.setStatus("No matching bracket", 1)
+		return
+	}
+	e.recordJump()
+	e.cursor.moveTo(match.row, match.col)
+	e.cursor.anchor = match.col
+}
+
+// bracketCache is the bracket match for a cursor position in a version
+// of a document
+type bracketCache struct {
+	doc     *Document
+	version int
+	cursor  position
+	match   *position
+}
+
+// bracketMatch returns the position of the bracket matching the one at
+// the cursor for highlighting, or nil. The match is only searched again
+// when the cursor moved or the document changed.
+func (e *Editor) bracketMatch() *position {
+	if e.mode != EditMode {
+		return nil
+	}
+	cursor := position{e.cursor.row, e.cursor.col}
+	cache := &e.brackets
+	if cache.doc == e.document && cache.version == e.document.version && cache.cursor == cursor {
+		return cache.match
+	}
+	*cache = bracketCache{doc: e.document, version: e.document.version, cursor: cursor}
+	pos, ok := e.document.bracketAt(cursor.row, cursor.col)
+	if !ok {
+		return nil
+	}
+	if match, ok := e.document.matchBracket(pos); ok {
+		cache.match = &match
+	}
+	return cache.match
+}
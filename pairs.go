@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+)
+
+func (e *Editor) autoPairsEnabled() bool {
+	return e.document.config.autoPairsFor(e.document.language().name)
+}
+
+// closingPair returns the rune that is auto-inserted after r,
+// for brackets and for the quotes of the document's language
+func (e *Editor) closingPair(r rune) (rune, bool) {
+	if isOpener(r) {
+		return closerFor(r), true
+	}
+	if e.isQuote(r) {
+		return r, true
+	}
+	return 0, false
+}
+
+func (e *Editor) isQuote(r rune) bool {
+	lang := e.document.language()
+	return strings.ContainsRune(lang.quotes+lang.rawQuotes, r)
+}
+
+// insertPaired types r with auto-pairing: an opener also inserts its
+// closer, and a closer next to the cursor is stepped over. It reports
+// whether it handled the rune.
+func (e *Editor) insertPaired(r rune) bool {
+	if !e.autoPairsEnabled() {
+		return false
+	}
+	row, col := e.cursor.coords()
+	content, err := e.document.getLine(row)
+	if err != nil {
+		return false
+	}
+	runes := []rune(content)
+	var prev, next rune
+	if col > 0 {
+		prev = runes[col-1]
+	}
+	if col < len(runes) {
+		next = runes[col]
+	}
+
+	if next == r && (isCloser(r) || e.isQuote(r)) {
+		e.moveRight()
+		return true
+	}
+
+	closer, ok := e.closingPair(r)
+	if !ok || isWordRune(next) {
+		return false
+	}
+	// quotes inside a word are apostrophes, not strings
+	if e.isQuote(r) && isWordRune(prev) {
+		return false
+	}
+
+	err = e.document.insertRune(row, col, r)
+	if err == nil {
+		err = e.document.insertRune(row, col+1, closer)
+	}
+	if e.handleError("failed to insert pair", err) {
+		return true
+	}
+	e.moveRight()
+	return true
+}
+
+// deletePair removes an empty pair around the cursor on backspace,
+// reporting whether it did
+func (e *Editor) deletePair() bool {
+	if !e.autoPairsEnabled() {
+		return false
+	}
+	row, col := e.cursor.coords()
+	content, err := e.document.getLine(row)
+	if err != nil {
+		return false
+	}
+	runes := []rune(content)
+	if col == 0 || col >= len(runes) {
+		return false
+	}
+	closer, ok := e.closingPair(runes[col-1])
+	if !ok || runes[col] != closer {
+		return false
+	}
+	err = e.document.deleteRunes(row, col-1, col+1)
+	if e.handleError("failed to delete pair", err) {
+		return true
+	}
+	e.cursor.moveTo(row, col-1)
+	e.cursor.anchor = col - 1
+	e.setDirty()
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestInsertPaired(t *testing.T) {
+	tests := []struct {
+		name, file, line string
+		col              int
+		r                rune
+		handled          bool
+		want             string
+		wantCol          int
+	}{
+		{"bracket", "a.c", "", 0, '(', true, "()", 1},
+		{"bracket before space", "a.c", "f x", 1, '[', true, "f[] x", 2},
+		{"bracket before a word", "a.c", "x", 0, '(', false, "x", 0},
+		{"step over a closer", "a.c", "()", 1, ')', true, "()", 2},
+		{"closer elsewhere", "a.c", "(x", 2, ')', false, "(x", 2},
+		{"quote", "a.c", "", 0, '"', true, `""`, 1},
+		{"step over a quote", "a.c", `""`, 1, '"', true, `""`, 2},
+		{"apostrophe", "a.c", "it", 2, '\'', false, "it", 2},
+		{"raw quote", "a.go", "", 0, '`', true, "``", 1},
+		{"quote without strings", "a.txt", "", 0, '"', false, "", 0},
+		{"other rune", "a.c", "", 0, 'a', false, "", 0},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, tt.file, tt.line)
+		e.cursor.moveTo(0, tt.col)
+		handled := e.insertPaired(tt.r)
+		got := e.document.lines[0].content
+		if handled != tt.handled || got != tt.want || e.cursor.col != tt.wantCol {
+			t.Errorf("%s: %v, %q at %d, want %v, %q at %d", tt.name, handled, got, e.cursor.col, tt.handled, tt.want, tt.wantCol)
+		}
+	}
+}
+
+func TestInsertPairedDisabled(t *testing.T) {
+	e := newTestEditor(t, "a.md", "")
+	e.document.config.AutoPairsByLanguage = map[string]bool{"markdown": false}
+	if e.insertPaired('(') {
+		t.Error("paired a bracket with auto_pairs.markdown=false")
+	}
+	e = newTestEditor(t, "a.c", "")
+	e.document.config.AutoPairs = false
+	if e.insertPaired('(') {
+		t.Error("paired a bracket with auto_pairs=false")
+	}
+}
+
+func TestDeletePair(t *testing.T) {
+	tests := []struct {
+		name, line string
+		col        int
+		deleted    bool
+		want       string
+	}{
+		{"brackets", "f()", 2, true, "f"},
+		{"quotes", `x = ""`, 5, true, "x = "},
+		{"not empty", "(x)", 1, false, "(x)"},
+		{"mismatched", "(]", 1, false, "(]"},
+		{"start of line", "()", 0, false, "()"},
+		{"end of line", "()", 2, false, "()"},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "a.c", tt.line)
+		e.cursor.moveTo(0, tt.col)
+		deleted := e.deletePair()
+		if got := e.document.lines[0].content; deleted != tt.deleted || got != tt.want {
+			t.Errorf("%s: %v, %q, want %v, %q", tt.name, deleted, got, tt.deleted, tt.want)
+		}
+		if deleted && e.cursor.col != tt.col-1 {
+			t.Errorf("%s: cursor at %d, want %d", tt.name, e.cursor.col, tt.col-1)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const PALETTE_ROWS = 10
+
+type Palette struct {
+	query    string
+	matches  []Command
+	selected int
+}
+
+type paletteMatch struct {
+	cmd   Command
+	score int
+}
+
+func (p *Palette) reset() {
+	p.query = ""
+	p.matches = nil
+	p.selected = 0
+}
+
+// filter recomputes the matching commands for the current query,
+// best matches first
+func (p *Palette) filter(cmds []Command) {
+	var scored []paletteMatch
+	for _, cmd := range cmds {
+		score, ok := fuzzyScore(p.query, cmd.name+" "+cmd.desc)
+		if ok {
+			scored = append(scored, paletteMatch{cmd, score})
+		}
+	}
+	slices.SortStableFunc(scored, func(a, b paletteMatch) int {
+		return b.score - a.score
+	})
+	p.matches = p.matches[:0]
+	for _, m := range scored {
+		p.matches = append(p.matches, m.cmd)
+	}
+	p.selected = 0
+}
+
+func (p *Palette) editQuery(r rune, cmds []Command) {
+	switch r {
+	case BACKSPACE, DELETE:
+		if len(p.query) > 0 {
+			runes := []rune(p.query)
+			p.query = string(runes[:len(runes)-1])
+		}
+	default:
+		if !unicode.IsPrint(r) {
+			return
+		}
+		p.query += string(r)
+	}
+	p.filter(cmds)
+}
+
+func (p *Palette) moveSelection(delta int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.selected = (p.selected + delta + len(p.matches)) % len(p.matches)
+}
+
+// current returns the selected command, if there is one
+func (p *Palette) current() (Command, bool) {
+	if p.selected < 0 || p.selected >= len(p.matches) {
+		return Command{}, false
+	}
+	return p.matches[p.selected], true
+}
+
+// fuzzyScore reports whether every rune of pattern appears in s in order,
+// ignoring case, and scores the match so that consecutive runs and
+// matches at the start of words rank higher
+func fuzzyScore(pattern, s string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	pat := []rune(strings.ToLower(pattern))
+	text := []rune(strings.ToLower(s))
+
+	score := 0
+	pi := 0
+	prevMatch := -2
+	for i, r := range text {
+		if pi == len(pat) {
+			break
+		}
+		if r != pat[pi] {
+			continue
+		}
+		score++
+		if i == prevMatch+1 {
+			score += 5
+		}
+		if i == 0 || !unicode.IsLetter(text[i-1]) {
+			score += 3
+		}
+		prevMatch = i
+		pi++
+	}
+	if pi < len(pat) {
+		return 0, false
+	}
+	return score - len(text)/10, true
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		ok         bool
+	}{
+		{"", "save", true},
+		{"sv", "save", true},
+		{"SAVE", "save", true},
+		{"sa", "find", false},
+		{"evas", "save", false},
+		{"mlu", "move-lines-up", true},
+		{"ü", "menü", true},
+	}
+	for _, tt := range tests {
+		if _, ok := fuzzyScore(tt.pattern, tt.s); ok != tt.ok {
+			t.Errorf("fuzzyScore(%q, %q) matched = %v, want %v", tt.pattern, tt.s, ok, tt.ok)
+		}
+	}
+}
+
+func TestFuzzyScoreRanking(t *testing.T) {
+	tests := []struct {
+		pattern, better, worse string
+	}{
+		// a consecutive run beats scattered letters
+		{"line", "join-lines", "last-in-one"},
+		// word starts beat letters inside words
+		{"mlu", "move-lines-up", "multiple"},
+		// long names that merely contain the letters rank lower
+		{"sv", "save", "select-every-visible-line-of-the-document"},
+	}
+	for _, tt := range tests {
+		better, _ := fuzzyScore(tt.pattern, tt.better)
+		worse, _ := fuzzyScore(tt.pattern, tt.worse)
+		if better <= worse {
+			t.Errorf("%q: %q scored %d, not above %q with %d", tt.pattern, tt.better, better, tt.worse, worse)
+		}
+	}
+}
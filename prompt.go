@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const HISTORY_SIZE = 100
+
+type History struct {
+	entries []string
+}
+
+// add records an entry, skipping repeats of the most recent one
+func (h *History) add(entry string) {
+	if entry == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > HISTORY_SIZE {
+		h.entries = h.entries[1:]
+	}
+}
+
+// Prompt is a single line input shown in the footer
+type Prompt struct {
+	label    string
+	input    []rune
+	pos      int
+	history  *History
+	histIdx  int
+	complete func(input string) []string
+	submit   func(input string)
+
+	candidates []string
+	candIdx    int
+}
+
+func NewPrompt(label, initial string, history *History, complete func(string) []string, submit func(string)) *Prompt {
+	p := Prompt{
+		label:    label,
+		input:    []rune(initial),
+		pos:      len([]rune(initial)),
+		history:  history,
+		complete: complete,
+		submit:   submit,
+	}
+	if history != nil {
+		p.histIdx = len(history.entries)
+	}
+	return &p
+}
+
+func (p *Prompt) text() string {
+	return string(p.input)
+}
+
+func (p *Prompt) setText(s string) {
+	p.input = []rune(s)
+	p.pos = len(p.input)
+}
+
+// edit applies a key press to the input line
+func (p *Prompt) edit(r rune) {
+	if r != TAB {
+		p.candidates = nil
+	}
+	switch r {
+	case BACKSPACE, DELETE:
+		if p.pos > 0 {
+			p.input = slices.Delete(p.input, p.pos-1, p.pos)
+			p.pos--
+		}
+	case ARROW_LEFT:
+		p.pos = max(p.pos-1, 0)
+	case ARROW_RIGHT:
+		p.pos = min(p.pos+1, len(p.input))
+	case HOME:
+		p.pos = 0
+	case END:
+		p.pos = len(p.input)
+	case ARROW_UP:
+		p.historyStep(-1)
+	case ARROW_DOWN:
+		p.historyStep(1)
+	case TAB:
+		p.completeInput()
+	default:
+		if unicode.IsPrint(r) {
+			p.input = slices.Insert(p.input, p.pos, r)
+			p.pos++
+		}
+	}
+}
+
+func (p *Prompt) historyStep(delta int) {
+	if p.history == nil {
+		return
+	}
+	idx := p.histIdx + delta
+	if idx < 0 || idx > len(p.history.entries) {
+		return
+	}
+	p.histIdx = idx
+	if idx == len(p.history.entries) {
+		p.setText("")
+		return
+	}
+	p.setText(p.history.entries[idx])
+}
+
+// completeInput extends the input to the longest common prefix of the
+// candidates, and cycles through them on repeated presses
+func (p *Prompt) completeInput() {
+	if p.complete == nil {
+		return
+	}
+	if len(p.candidates) > 1 {
+		p.candIdx = (p.candIdx + 1) % len(p.candidates)
+		p.setText(p.candidates[p.candIdx])
+		return
+	}
+
+	candidates := p.complete(p.text())
+	switch len(candidates) {
+	case 0:
+		return
+	case 1:
+		p.setText(candidates[0])
+		return
+	}
+
+	prefix := commonPrefix(candidates)
+	if utf8.RuneCountInString(prefix) > utf8.RuneCountInString(p.text()) {
+		p.setText(prefix)
+		return
+	}
+	p.candidates = candidates
+	p.candIdx = 0
+	p.setText(candidates[0])
+}
+
+// commonPrefix returns the longest prefix shared by all strings
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	prefix := []rune(ss[0])
+	for _, s := range ss[1:] {
+		// trim whole runes so the prefix stays valid UTF-8
+		for !strings.HasPrefix(s, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
+
+// completePath lists the files and directories starting with prefix,
+// directories carry a trailing separator
+func completePath(prefix string) []string {
+	expanded := prefix
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(prefix, "~/") {
+		expanded = filepath.Join(home, prefix[2:])
+		if strings.HasSuffix(prefix, "/") {
+			expanded += "/"
+		}
+	}
+
+	dir, base := filepath.Split(expanded)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	shownDir := prefix[:len(prefix)-len(base)]
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, shownDir+name)
+	}
+	return matches
+}
+
+// expandPath resolves a leading ~ to the user's home directory
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		ss   []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"write"}, "write"},
+		{[]string{"write", "wrap", "w"}, "w"},
+		{[]string{"src/", "src/main.go"}, "src/"},
+		{[]string{"abc", "xyz"}, ""},
+		{[]string{"héllo", "hèllo"}, "h"},
+		{[]string{"日本語", "日本"}, "日本"},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.ss); got != tt.want {
+			t.Errorf("commonPrefix(%q) = %q, want %q", tt.ss, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type optionKind byte
+
+const (
+	BoolOption   optionKind = iota
+	IntOption               // an integer of at least min
+	ChoiceOption            // one of choices
+	StringOption            // any text
+)
+
+// option describes a setting of the config file. Options whose key ends in
+// a dot, such as formatter., take the language name as a suffix.
+type option struct {
+	key     string
+	flag    string // command line flag name, if not the key with dashes
+	kind    optionKind
+	min     int
+	choices []string
+	desc    string
+}
+
+// configSchema lists every setting, in the order they are documented
+var configSchema = []option{
+	{key: "show_line_numbers", flag: "line-numbers", kind: BoolOption, desc: "Show line numbers in the gutter"},
+	{key: "expand_tabs", kind: BoolOption, desc: "Insert spaces instead of tabs"},
+	{key: "tab_size", kind: IntOption, min: 1, desc: "Width of a tab and of one indentation level"},
+	{key: "scroll_margin", kind: IntOption, min: 0, desc: "Lines kept visible above and below the cursor"},
+	{key: "auto_indent", kind: BoolOption, desc: "Keep and adjust indentation on new lines"},
+	{key: "auto_pairs", kind: BoolOption, desc: "Auto-close brackets and quotes"},
+	{key: "kill_ring_size", kind: IntOption, min: 1, desc: "Number of cuts and copies kept for pasting"},
+	{key: "format_on_save", kind: BoolOption, desc: "Run the formatter of the file's language on save"},
+	{key: "trim_trailing_whitespace", kind: BoolOption, desc: "Strip whitespace from line ends on save"},
+	{key: "trim_skip_cursor_line", kind: BoolOption, desc: "Leave the cursor line alone when trimming"},
+	{key: "keep_markdown_breaks", kind: BoolOption, desc: "Keep Markdown hard line breaks when trimming"},
+	{key: "final_newline", kind: BoolOption, desc: "Remove blank lines at the end of the file on save"},
+	{key: "convert_indentation", kind: BoolOption, desc: "Rewrite indentation as tabs or spaces on save"},
+	{key: "insert_final_newline", kind: BoolOption, desc: "End the last line with a newline"},
+	{key: "end_of_line", kind: ChoiceOption, choices: []string{"lf", "crlf", "cr"}, desc: "Line ending written on save"},
+	{key: "charset", kind: ChoiceOption, choices: []string{"utf-8", "utf-8-bom", "latin1"}, desc: "Encoding of files"},
+	{key: "max_line_length", kind: IntOption, min: 0, desc: "Highlight text past this column, 0 for off"},
+	{key: "auto_pairs.", kind: BoolOption, desc: "auto_pairs for one language, e.g. auto_pairs.markdown"},
+	{key: "formatter.", kind: StringOption, desc: "Shell command that formats a language, e.g. formatter.go"},
+}
+
+// configKeys lists the keys accepted by the config file and Config.set,
+// without the per-language ones
+var configKeys = optionKeys()
+
+func optionKeys() []string {
+	var keys []string
+	for _, opt := range configSchema {
+		if !opt.perLanguage() {
+			keys = append(keys, opt.key)
+		}
+	}
+	return keys
+}
+
+// perLanguage reports whether the option is set per language
+func (o option) perLanguage() bool {
+	return strings.HasSuffix(o.key, ".")
+}
+
+// lookupOption finds the option for a key; per-language keys must name a
+// known language
+func lookupOption(key string) (option, bool) {
+	for _, opt := range configSchema {
+		if opt.key == key {
+			return opt, !opt.perLanguage()
+		}
+		if lang, ok := strings.CutPrefix(key, opt.key); ok && opt.perLanguage() && isLanguageName(lang) {
+			return opt, true
+		}
+	}
+	return option{}, false
+}
+
+// checkSetting validates a key and its value without applying them
+func checkSetting(key, val string) error {
+	opt, ok := lookupOption(key)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownConfigKey, key)
+	}
+	return opt.check(val)
+}
+
+// check validates a value for the option
+func (o option) check(val string) error {
+	switch o.kind {
+	case BoolOption:
+		if _, err := strconv.ParseBool(val); err != nil {
+			return fmt.Errorf("%w: %s must be true or false", ErrInvalidConfigValue, o.name())
+		}
+	case IntOption:
+		n, err := strconv.Atoi(val)
+		if err != nil || n < o.min {
+			return fmt.Errorf("%w: %s must be %s", ErrInvalidConfigValue, o.name(), o.rangeText())
+		}
+	case ChoiceOption:
+		for _, choice := range o.choices {
+			if val == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s must be %s", ErrInvalidConfigValue, o.name(), o.rangeText())
+	}
+	return nil
+}
+
+// name returns the key as written in the config file
+func (o option) name() string {
+	if o.perLanguage() {
+		return o.key + "<language>"
+	}
+	return o.key
+}
+
+// rangeText describes the values the option accepts
+func (o option) rangeText() string {
+	switch o.kind {
+	case BoolOption:
+		return "true or false"
+	case IntOption:
+		if o.min == 0 {
+			return "a number 0 or greater"
+		}
+		return fmt.Sprintf("a number greater than %d", o.min-1)
+	case ChoiceOption:
+		last := len(o.choices) - 1
+		return strings.Join(o.choices[:last], ", ") + " or " + o.choices[last]
+	}
+	return "any text"
+}
+
+// suggestKey returns the known key closest to a misspelled one, or ""
+func suggestKey(key string) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	}
+	best, bestDistance := "", 3
+	for _, known := range configKeys {
+		if normalize(known) == normalize(key) {
+			return known
+		}
+		if d := editDistance(known, key); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance counts the single character edits that turn a into b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// describeDefaults lists every option with its default, accepted values
+// and description
+func describeDefaults() []string {
+	defaults := DefaultConfig()
+	var lines []string
+	for _, opt := range configSchema {
+		entry := opt.name()
+		if !opt.perLanguage() {
+			entry += "=" + defaults.get(opt.key)
+		}
+		lines = append(lines, fmt.Sprintf("%-32s %s", entry, opt.rangeText()))
+		lines = append(lines, "    "+opt.desc)
+	}
+	return lines
+}
+
+// settingFlag is a command line flag that sets an option. Flags of
+// options that default to true are negated, e.g. --no-line-numbers.
+type settingFlag struct {
+	opt    option
+	name   string
+	negate bool
+	cli    *[]setting
+}
+
+func (f *settingFlag) String() string {
+	if f.cli == nil || f.opt.kind == BoolOption {
+		return ""
+	}
+	return DefaultConfig().get(f.opt.key)
+}
+
+func (f *settingFlag) Set(val string) error {
+	if f.negate {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		val = strconv.FormatBool(!b)
+	}
+	err := f.opt.check(val)
+	if err != nil {
+		return err
+	}
+	*f.cli = append(*f.cli, setting{key: f.opt.key, value: val, origin: "--" + f.name})
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.opt.kind == BoolOption
+}
+
+// defineSettingFlags adds a flag for every option that is not set per
+// language; the flags append what they set to cli
+func defineSettingFlags(fs *flag.FlagSet, cli *[]setting) {
+	defaults := DefaultConfig()
+	for _, opt := range configSchema {
+		if opt.perLanguage() {
+			continue
+		}
+		f := &settingFlag{opt: opt, name: opt.flag, cli: cli}
+		if f.name == "" {
+			f.name = strings.ReplaceAll(opt.key, "_", "-")
+		}
+		usage := opt.desc
+		switch opt.kind {
+		case BoolOption:
+			if defaults.get(opt.key) == "true" {
+				f.negate = true
+				f.name = "no-" + f.name
+				usage = fmt.Sprintf("Turn off %s: %s", opt.key, strings.ToLower(usage[:1])+usage[1:])
+			}
+		case IntOption:
+			usage += ", " + strings.Replace(opt.rangeText(), "number", "`number`", 1)
+		case ChoiceOption:
+			usage += ", " + opt.rangeText()
+		}
+		fs.Var(f, f.name, usage)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"tab_size", "tab_size", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"tab_size", "tab_sise", 1},
+		{"tab_size", "tabsize", 1},
+		{"tab_size", "tab_sizes", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestKey(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"tabsize", "tab_size"},
+		{"Tab-Size", "tab_size"},
+		{"tab_sise", "tab_size"},
+		{"expandtabs", "expand_tabs"},
+		{"auto_indnt", "auto_indent"},
+		{"colorscheme", ""},
+	}
+	for _, tt := range tests {
+		if got := suggestKey(tt.key); got != tt.want {
+			t.Errorf("suggestKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSetting(t *testing.T) {
+	tests := []struct {
+		key, val string
+		want     error
+	}{
+		{"tab_size", "2", nil},
+		{"tab_size", "0", ErrInvalidConfigValue},
+		{"tab_size", "two", ErrInvalidConfigValue},
+		{"scroll_margin", "0", nil},
+		{"expand_tabs", "yes", ErrInvalidConfigValue},
+		{"end_of_line", "crlf", nil},
+		{"end_of_line", "dos", ErrInvalidConfigValue},
+		{"auto_pairs.markdown", "false", nil},
+		{"auto_pairs.text", "false", nil},
+		{"auto_pairs.bogus", "false", ErrUnknownConfigKey},
+		{"auto_pairs.", "false", ErrUnknownConfigKey},
+		{"auto_pairs", "false", nil},
+		{"formatter.go", "", nil},
+		{"formatter.", "gofmt", ErrUnknownConfigKey},
+		{"tabsize", "2", ErrUnknownConfigKey},
+	}
+	for _, tt := range tests {
+		err := checkSetting(tt.key, tt.val)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("checkSetting(%q, %q) = %v, want %v", tt.key, tt.val, err, tt.want)
+		}
+	}
+}
+
+func TestDefineSettingFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []setting
+		ok   bool
+	}{
+		{
+			args: []string{"--tab-size", "2", "--expand-tabs"},
+			want: []setting{
+				{key: "tab_size", value: "2", origin: "--tab-size"},
+				{key: "expand_tabs", value: "true", origin: "--expand-tabs"},
+			},
+			ok: true,
+		},
+		{
+			args: []string{"--no-line-numbers", "--no-auto-pairs=false"},
+			want: []setting{
+				{key: "show_line_numbers", value: "false", origin: "--no-line-numbers"},
+				{key: "auto_pairs", value: "true", origin: "--no-auto-pairs"},
+			},
+			ok: true,
+		},
+		{
+			args: []string{"--end-of-line=crlf", "--max-line-length", "0"},
+			want: []setting{
+				{key: "end_of_line", value: "crlf", origin: "--end-of-line"},
+				{key: "max_line_length", value: "0", origin: "--max-line-length"},
+			},
+			ok: true,
+		},
+		{args: []string{"--tab-size", "0"}},
+		{args: []string{"--end-of-line", "dos"}},
+		{args: []string{"--line-numbers"}},
+		{args: []string{"--auto-pairs.markdown=false"}},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("gtext", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		var cli []setting
+		defineSettingFlags(fs, &cli)
+		err := fs.Parse(tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: error = %v, want ok %v", tt.args, err, tt.ok)
+			continue
+		}
+		if tt.ok && !slices.Equal(cli, tt.want) {
+			t.Errorf("%q: settings = %v, want %v", tt.args, cli, tt.want)
+		}
+	}
+}
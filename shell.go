@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	MESSAGE_ROWS = 10
+	// SHELL_WAIT_DELAY bounds how long a cancelled command may keep its
+	// output open, e.g. through a child process that was not killed
+	SHELL_WAIT_DELAY = 500 * time.Millisecond
+)
+
+const ErrJobRunning = gtextError("a shell command is already running")
+
+// shellJob is an external command running in the background. done is
+// called on the editor goroutine once the command has finished, unless
+// it was cancelled.
+type shellJob struct {
+	command string
+	cancel  context.CancelFunc
+	done    func(shellResult)
+}
+
+type shellResult struct {
+	stdout, stderr string
+	err            error
+	cancelled      bool
+}
+
+// outputLines splits command output into lines, ignoring the final newline
+func (r shellResult) outputLines() []string {
+	out := strings.TrimSuffix(r.stdout, "\n")
+	if out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// failure describes a failed command for the message area, using its
+// standard error when it wrote any
+func (r shellResult) failure(command string) []string {
+	lines := []string{fmt.Sprintf("%s: %v", command, r.err)}
+	stderr := strings.TrimSpace(r.stderr)
+	if stderr != "" {
+		lines = append(lines, strings.Split(stderr, "\n")...)
+	}
+	return lines
+}
+
+// runShell starts command with stdin as its input without blocking the
+// editor; Esc cancels it
+func (e *Editor) runShell(command, stdin string, done func(shellResult)) error {
+	if e.job != nil {
+		return ErrJobRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = SHELL_WAIT_DELAY
+
+	err := cmd.Start()
+	if err != nil {
+		cancel()
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	e.job = &shellJob{command: command, cancel: cancel, done: done}
+	e.setStatus(fmt.Sprintf("running %s (Esc to cancel)", command), 0)
+	go func() {
+		err := cmd.Wait()
+		e.jobChan <- shellResult{
+			stdout:    stdout.String(),
+			stderr:    stderr.String(),
+			err:       err,
+			cancelled: errors.Is(ctx.Err(), context.Canceled),
+		}
+		cancel()
+	}()
+	return nil
+}
+
+// handleJobKey handles keys while a command runs: Esc cancels it and
+// everything else is ignored so the document cannot change under it
+func (e *Editor) handleJobKey(r rune) {
+	if r == ESCAPE {
+		e.job.cancel()
+		e.setStatus(fmt.Sprintf("cancelling %s", e.job.command), 0)
+		return
+	}
+	e.setStatus(fmt.Sprintf("running %s (Esc to cancel)", e.job.command), 0)
+}
+
+// finishJob reports the result of the background command
+func (e *Editor) finishJob(res shellResult) {
+	job := e.job
+	e.job = nil
+	e.clearStatus()
+	if res.cancelled {
+		e.setStatus(fmt.Sprintf("cancelled %s", job.command), 2)
+		return
+	}
+	job.done(res)
+}
+
+// onSuccess wraps a handler so that it only sees commands that succeeded,
+// showing the errors of failed ones in the message area
+func (e *Editor) onSuccess(command string, done func(shellResult)) func(shellResult) {
+	return func(res shellResult) {
+		if res.err != nil {
+			e.message = res.failure(command)
+			return
+		}
+		done(res)
+	}
+}
+
+// execShell runs a command, showing its output in the message area
+func (e *Editor) execShell(args cmdArgs) error {
+	command := strings.TrimSpace(args.str("command"))
+	if command == "" {
+		return fmt.Errorf("%w command", ErrMissingArg)
+	}
+	return e.runShell(command, "", e.onSuccess(command, func(res shellResult) {
+		lines := res.outputLines()
+		switch len(lines) {
+		case 0:
+			e.setStatus(fmt.Sprintf("%s: done", command), 2)
+		case 1:
+			e.setStatus(lines[0], 3)
+		default:
+			e.message = lines
+		}
+	}))
+}
+
+// execPipe replaces the marked or all lines with the output of a command
+// that reads them on its standard input
+func (e *Editor) execPipe(args cmdArgs) error {
+	command := strings.TrimSpace(args.str("command"))
+	r := e.targetLines()
+	input := strings.Join(e.document.contents()[r.start:r.end+1], "\n") + "\n"
+	return e.runShell(command, input, e.onSuccess(command, func(res shellResult) {
+		lines := res.outputLines()
+		err := e.document.replaceLines(r.start, r.end+1, lines)
+		if e.handleError("could not replace lines", err) {
+			return
+		}
+		e.setDirty()
+		e.clearMark()
+		row := min(r.start, e.document.lineCount()-1)
+		e.cursor.moveTo(row, 0)
+		e.cursor.anchor = 0
+		e.setStatus(fmt.Sprintf("replaced %d lines with %d", r.count(), len(lines)), 2)
+	}))
+}
+
+// execRead inserts the output of a command below the cursor line
+func (e *Editor) execRead(args cmdArgs) error {
+	command := strings.TrimSpace(args.str("command"))
+	row := e.cursor.row + 1
+	return e.runShell(command, "", e.onSuccess(command, func(res shellResult) {
+		lines := res.outputLines()
+		row := min(row, e.document.lineCount())
+		err := e.document.replaceLines(row, row, lines)
+		if e.handleError("could not insert output", err) {
+			return
+		}
+		if len(lines) > 0 {
+			e.setDirty()
+		}
+		e.setStatus(fmt.Sprintf("read %d lines", len(lines)), 2)
+	}))
+}
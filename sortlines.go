@@ -0,0 +1,221 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const ErrInvalidSortOption = gtextError("invalid sort option")
+
+var numberPattern = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// sortOptions are the letters accepted by the sort command
+type sortOptions struct {
+	reverse    bool
+	numeric    bool
+	natural    bool
+	ignoreCase bool
+	unique     bool
+}
+
+func parseSortOptions(s string) (sortOptions, error) {
+	var opts sortOptions
+	for _, r := range s {
+		switch r {
+		case 'r':
+			opts.reverse = true
+		case 'n':
+			opts.numeric = true
+		case 'v':
+			opts.natural = true
+		case 'i':
+			opts.ignoreCase = true
+		case 'u':
+			opts.unique = true
+		default:
+			return opts, fmt.Errorf("%w %q: use r, n, v, i or u", ErrInvalidSortOption, r)
+		}
+	}
+	return opts, nil
+}
+
+// compare orders two lines according to the options, before reversing
+func (o sortOptions) compare(a, b string) int {
+	if o.ignoreCase {
+		a, b = strings.ToLower(a), strings.ToLower(b)
+	}
+	switch {
+	case o.numeric:
+		return cmp.Or(cmp.Compare(leadingNumber(a), leadingNumber(b)), strings.Compare(a, b))
+	case o.natural:
+		return naturalCompare(a, b)
+	}
+	return strings.Compare(a, b)
+}
+
+// leadingNumber returns the first number in a line; lines without one
+// sort before all others
+func leadingNumber(s string) float64 {
+	n, err := strconv.ParseFloat(numberPattern.FindString(s), 64)
+	if err != nil {
+		return math.Inf(-1)
+	}
+	return n
+}
+
+// naturalCompare compares strings treating runs of digits as numbers,
+// so that "file2" sorts before "file10"
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aNum, aRest := splitDigits(a)
+		bNum, bRest := splitDigits(b)
+		if aNum != "" && bNum != "" {
+			aTrim := strings.TrimLeft(aNum, "0")
+			bTrim := strings.TrimLeft(bNum, "0")
+			c := cmp.Or(cmp.Compare(len(aTrim), len(bTrim)), strings.Compare(aTrim, bTrim))
+			if c != 0 {
+				return c
+			}
+			a, b = aRest, bRest
+			continue
+		}
+		if a[0] != b[0] {
+			return cmp.Compare(a[0], b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// splitDigits splits the leading run of digits off a string
+func splitDigits(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// sortLines sorts lines in place, stably, and drops duplicates if asked
+func sortLines(lines []string, opts sortOptions) []string {
+	slices.SortStableFunc(lines, func(a, b string) int {
+		if opts.reverse {
+			return opts.compare(b, a)
+		}
+		return opts.compare(a, b)
+	})
+	if opts.unique {
+		lines = slices.CompactFunc(lines, func(a, b string) bool {
+			return opts.compare(a, b) == 0
+		})
+	}
+	return lines
+}
+
+// uniqueLines removes repeated lines, keeping the first occurrence
+func uniqueLines(lines []string) []string {
+	seen := make(map[string]bool)
+	return slices.DeleteFunc(lines, func(l string) bool {
+		dup := seen[l]
+		seen[l] = true
+		return dup
+	})
+}
+
+// targetLines returns the marked lines, or the whole document
+func (e *Editor) targetLines() lineRange {
+	if r, ok := e.markedLines(); ok {
+		return r
+	}
+	return lineRange{0, e.document.lineCount() - 1}
+}
+
+// transformLines replaces the marked or all lines with the result of
+// transform as a single change to the document
+func (e *Editor) transformLines(transform func([]string) []string) (int, int, error) {
+	r := e.targetLines()
+	lines := e.document.contents()[r.start : r.end+1]
+	result := transform(lines)
+	err := e.document.replaceLines(r.start, r.end+1, result)
+	if err != nil {
+		return 0, 0, err
+	}
+	e.setDirty()
+	e.clearMark()
+	row := min(r.start, e.document.lineCount()-1)
+	e.cursor.moveTo(row, 0)
+	e.cursor.anchor = 0
+	return r.count(), len(result), nil
+}
+
+func (e *Editor) execSort(args cmdArgs) error {
+	opts, err := parseSortOptions(args.str("options"))
+	if err != nil {
+		return err
+	}
+	before, after, err := e.transformLines(func(lines []string) []string {
+		return sortLines(lines, opts)
+	})
+	if err != nil {
+		return err
+	}
+	status := fmt.Sprintf("sorted %d lines", before)
+	if opts.unique {
+		status += fmt.Sprintf(", %d duplicates removed", before-after)
+	}
+	e.setStatus(status, 2)
+	return nil
+}
+
+func (e *Editor) execUniq(args cmdArgs) error {
+	before, after, err := e.transformLines(uniqueLines)
+	if err != nil {
+		return err
+	}
+	e.setStatus(fmt.Sprintf("removed %d duplicate lines", before-after), 2)
+	return nil
+}
+
+func (e *Editor) execReverse(args cmdArgs) error {
+	n, _, err := e.transformLines(func(lines []string) []string {
+		slices.Reverse(lines)
+		return lines
+	})
+	if err != nil {
+		return err
+	}
+	e.setStatus(fmt.Sprintf("reversed %d lines", n), 2)
+	return nil
+}
+
+func (e *Editor) execKeep(args cmdArgs) error {
+	return e.filterLines(args.str("pattern"), true)
+}
+
+func (e *Editor) execDrop(args cmdArgs) error {
+	return e.filterLines(args.str("pattern"), false)
+}
+
+// filterLines keeps the lines that match pattern, or those that do not
+func (e *Editor) filterLines(pattern string, keep bool) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%w pattern: %w", ErrInvalidArg, err)
+	}
+	before, after, err := e.transformLines(func(lines []string) []string {
+		return slices.DeleteFunc(lines, func(l string) bool {
+			return re.MatchString(l) != keep
+		})
+	})
+	if err != nil {
+		return err
+	}
+	e.setStatus(fmt.Sprintf("deleted %d of %d lines", before-after, before), 2)
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"a", "a", 0},
+		{"a", "b", -1},
+		{"file2", "file10", -1},
+		{"file10", "file2", 1},
+		{"file02", "file2", 0},
+		{"file007", "file10", -1},
+		{"x9y", "x10y", -1},
+		{"x10a", "x10b", -1},
+		{"10", "9", 1},
+		{"abc", "ab", 1},
+		{"a1b2", "a1b10", -1},
+		{"B", "a", -1},
+	}
+	for _, tt := range tests {
+		if got := naturalCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/term"
@@ -13,13 +15,23 @@ import (
 
 const (
 	// ASCII control characters
+	CTRL_E rune = 0x05
 	CTRL_F rune = 0x06
+	CTRL_G rune = 0x07
+	CTRL_N rune = 0x0e
+	CTRL_O rune = 0x0f
+	CTRL_P rune = 0x10
 	CTRL_Q rune = 0x11
 	CTRL_S rune = 0x13
 	CTRL_V rune = 0x16
+	CTRL_B rune = 0x02
 	CTRL_C rune = 0x03
+	CTRL_D rune = 0x04
 	CTRL_X rune = 0x18
 
+	CTRL_CLOSE_BRACKET rune = 0x1d
+	CTRL_SLASH         rune = 0x1f // sent as Ctrl-_
+
 	// Common keyboard characters
 	BACKSPACE rune = 0x08
 	TAB       rune = 0x09
@@ -40,6 +52,16 @@ const (
 	HOME        rune = 0xE006
 	END         rune = 0xE007
 	NEW_LINE    rune = 0xE008
+	DEL_KEY     rune = 0xE009
+)
+
+// Modifier bits set on keys pressed together with Alt, Ctrl or Shift.
+// Terminals send Alt-<key> as Escape followed by the key, and report
+// modifiers of special keys as a control sequence parameter.
+const (
+	ALT   rune = 0x200000
+	CTRL  rune = 0x400000
+	SHIFT rune = 0x800000
 )
 
 const (
@@ -53,10 +75,13 @@ const (
 )
 
 const (
-	HIGHLIGHT_MATCH = "\x1b[30;43m"
-	BLACK_ON_WHITE  = "\x1b[30;47m"       // Set foreground to black, background to white
-	BLACK_ON_GREY   = "\x1b[30;48;5;240m" // Set foreground to black, background to grey
-	RESET           = "\x1b[0m"           // Reset all SGR (Select Graphic Rendition) parameters
+	HIGHLIGHT_MATCH    = "\x1b[30;43m"
+	HIGHLIGHT_BRACKET  = "\x1b[30;46m"
+	HIGHLIGHT_OVERFLOW = "\x1b[30;41m"
+	REVERSE            = "\x1b[7m"           // Swap foreground and background
+	BLACK_ON_WHITE     = "\x1b[30;47m"       // Set foreground to black, background to white
+	BLACK_ON_GREY      = "\x1b[30;48;5;240m" // Set foreground to black, background to grey
+	RESET              = "\x1b[0m"           // Reset all SGR (Select Graphic Rendition) parameters
 )
 
 const (
@@ -67,6 +92,7 @@ const (
 )
 
 const ErrWindowNullSize = gtextError("window size reported as 0,0")
+const ErrUnknownKey = gtextError("unknown key")
 
 func getWindowSize() (int, int, error) {
 	ncol, nrow, err := term.GetSize(int(os.Stdout.Fd()))
@@ -87,51 +113,222 @@ func ReadKey(r *bufio.Reader) (rune, error) {
 	if ch != ESCAPE {
 		return ch, nil
 	}
-	seq, err := r.Peek(2)
-	if len(seq) != 2 || seq[0] != byte(CSI) {
+	// a lone Escape key press arrives on its own, without a sequence
+	if r.Buffered() == 0 {
 		return ESCAPE, nil
 	}
+	seq, err := r.Peek(1)
 	if err != nil {
 		return 0, err
 	}
+	// Escape followed by anything but a control sequence is an Alt key
+	if seq[0] != byte(CSI) || r.Buffered() < 2 {
+		next, _, err := r.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		return ALT | next, nil
+	}
+
+	r.ReadRune() // consume the CSI introducer
+	params, final, err := readControlSequence(r)
+	if err != nil {
+		return 0, err
+	}
+	key, ok := decodeControlSequence(params, final)
+	if !ok {
+		// keys the editor has no use for, such as F1-F12 or Insert
+		return 0, nil
+	}
+	return key, nil
+}
+
+// readControlSequence reads the parameter bytes and the final byte of a
+// control sequence, e.g. "1;5" and 'C' for Ctrl-Right
+func readControlSequence(r *bufio.Reader) (string, rune, error) {
+	var params strings.Builder
+	for {
+		ch, _, err := r.ReadRune()
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case ch >= 0x30 && ch <= 0x3f:
+			params.WriteRune(ch)
+		case ch >= 0x40 && ch <= 0x7e:
+			return params.String(), ch, nil
+		default:
+			return "", 0, ErrReturnSeqTerminator
+		}
+	}
+}
 
-	r.ReadRune()
-	ch, _, _ = r.ReadRune()
-	switch ch {
+// decodeControlSequence maps a control sequence to a key, including the
+// modifiers reported in its second parameter
+func decodeControlSequence(params string, final rune) (rune, bool) {
+	fields := strings.Split(params, ";")
+	var mods rune
+	if len(fields) > 1 {
+		mods = decodeModifiers(fields[1])
+	}
+
+	switch final {
 	case 'A':
-		return ARROW_UP, nil
+		return ARROW_UP | mods, true
 	case 'B':
-		return ARROW_DOWN, nil
+		return ARROW_DOWN | mods, true
 	case 'C':
-		return ARROW_RIGHT, nil
+		return ARROW_RIGHT | mods, true
 	case 'D':
-		return ARROW_LEFT, nil
+		return ARROW_LEFT | mods, true
 	case 'H':
-		return HOME, nil
+		return HOME | mods, true
 	case 'F':
-		return END, nil
-	case '5':
-		ch, _, _ := r.ReadRune()
-		if ch == '~' {
-			return PAGE_UP, nil
-		}
-	case '6':
-		ch, _, _ := r.ReadRune()
-		if ch == '~' {
-			return PAGE_DOWN, nil
+		return END | mods, true
+	case 'Z':
+		return SHIFT | TAB, true
+	case 'u':
+		// keys with modifiers as CSI code;mods u, e.g. Ctrl-Backspace
+		if fields[0] == "127" || fields[0] == "8" {
+			return DELETE | mods, true
 		}
-	case '1', '7':
-		ch, _, _ := r.ReadRune()
-		if ch == '~' {
-			return HOME, nil
+	case '~':
+		switch fields[0] {
+		case "27":
+			// xterm modifyOtherKeys: CSI 27;mods;code ~
+			if len(fields) == 3 && (fields[2] == "127" || fields[2] == "8") {
+				return DELETE | mods, true
+			}
+		case "1", "7":
+			return HOME | mods, true
+		case "3":
+			return DEL_KEY | mods, true
+		case "4", "8":
+			return END | mods, true
+		case "5":
+			return PAGE_UP | mods, true
+		case "6":
+			return PAGE_DOWN | mods, true
 		}
-	case '4', '8':
-		ch, _, _ := r.ReadRune()
-		if ch == '~' {
-			return END, nil
+	}
+	return 0, false
+}
+
+// decodeModifiers converts an xterm modifier parameter, which is
+// 1 + a bitmask of shift (1), alt (2), ctrl (4) and meta (8)
+func decodeModifiers(param string) rune {
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 1 {
+		return 0
+	}
+	bits := n - 1
+	var mods rune
+	if bits&1 != 0 {
+		mods |= SHIFT
+	}
+	if bits&(2|8) != 0 {
+		mods |= ALT
+	}
+	if bits&4 != 0 {
+		mods |= CTRL
+	}
+	return mods
+}
+
+// keyName returns a human readable label for a key, as used in hints
+func keyName(r rune) string {
+	switch r {
+	case 0:
+		return ""
+	case BACKSPACE:
+		return "Ctrl-H"
+	case TAB:
+		return "Tab"
+	case RETURN:
+		return "Enter"
+	case ESCAPE:
+		return "Esc"
+	case SPACE:
+		return "Space"
+	case DELETE:
+		return "Backspace"
+	case ARROW_UP:
+		return "Up"
+	case ARROW_DOWN:
+		return "Down"
+	case ARROW_RIGHT:
+		return "Right"
+	case ARROW_LEFT:
+		return "Left"
+	case PAGE_UP:
+		return "PgUp"
+	case PAGE_DOWN:
+		return "PgDn"
+	case HOME:
+		return "Home"
+	case END:
+		return "End"
+	case DEL_KEY:
+		return "Delete"
+	case CTRL_SLASH:
+		return "Ctrl-/"
+	}
+	if r&CTRL != 0 {
+		return "Ctrl-" + keyName(r&^CTRL)
+	}
+	if r&ALT != 0 {
+		return "Alt-" + keyName(r&^ALT)
+	}
+	if r&SHIFT != 0 {
+		return "Shift-" + keyName(r&^SHIFT)
+	}
+	if r < SPACE {
+		return fmt.Sprintf("Ctrl-%c", r+'@')
+	}
+	return string(r)
+}
+
+// namedKeys maps the names produced by keyName back to their keys
+var namedKeys = map[string]rune{
+	"Tab":       TAB,
+	"Enter":     RETURN,
+	"Esc":       ESCAPE,
+	"Space":     SPACE,
+	"Backspace": DELETE,
+	"Up":        ARROW_UP,
+	"Down":      ARROW_DOWN,
+	"Right":     ARROW_RIGHT,
+	"Left":      ARROW_LEFT,
+	"PgUp":      PAGE_UP,
+	"PgDn":      PAGE_DOWN,
+	"Home":      HOME,
+	"End":       END,
+	"Delete":    DEL_KEY,
+	"Ctrl-/":    CTRL_SLASH,
+}
+
+// parseKeyName is the inverse of keyName
+func parseKeyName(name string) (rune, error) {
+	if r, ok := namedKeys[name]; ok {
+		return r, nil
+	}
+	if rest, ok := strings.CutPrefix(name, "Ctrl-"); ok {
+		if runes := []rune(strings.ToUpper(rest)); len(runes) == 1 && runes[0] >= '@' && runes[0] <= '_' {
+			return runes[0] - '@', nil
 		}
-	default:
-		return ESCAPE, nil
+		r, err := parseKeyName(rest)
+		return CTRL | r, err
+	}
+	if rest, ok := strings.CutPrefix(name, "Alt-"); ok {
+		r, err := parseKeyName(rest)
+		return ALT | r, err
+	}
+	if rest, ok := strings.CutPrefix(name, "Shift-"); ok {
+		r, err := parseKeyName(rest)
+		return SHIFT | r, err
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		return runes[0], nil
 	}
-	return 0, ErrReturnSeqTerminator
+	return 0, fmt.Errorf("%w: %q", ErrUnknownKey, name)
 }
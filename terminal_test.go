@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeyNameRoundTrip(t *testing.T) {
+	tests := []struct {
+		key  rune
+		name string
+	}{
+		{'a', "a"},
+		{'=', "="},
+		{'é', "é"},
+		{TAB, "Tab"},
+		{RETURN, "Enter"},
+		{ESCAPE, "Esc"},
+		{SPACE, "Space"},
+		{DELETE, "Backspace"},
+		{BACKSPACE, "Ctrl-H"},
+		{DEL_KEY, "Delete"},
+		{ARROW_UP, "Up"},
+		{PAGE_DOWN, "PgDn"},
+		{CTRL_SLASH, "Ctrl-/"},
+		{'s' & 0x1f, "Ctrl-S"},
+		{CTRL | ARROW_RIGHT, "Ctrl-Right"},
+		{ALT | 'x', "Alt-x"},
+		{SHIFT | TAB, "Shift-Tab"},
+		{CTRL | SHIFT | ARROW_LEFT, "Ctrl-Shift-Left"},
+	}
+	for _, tt := range tests {
+		if got := keyName(tt.key); got != tt.name {
+			t.Errorf("keyName(%#x) = %q, want %q", tt.key, got, tt.name)
+		}
+		got, err := parseKeyName(tt.name)
+		if err != nil {
+			t.Errorf("parseKeyName(%q): %v", tt.name, err)
+		} else if got != tt.key {
+			t.Errorf("parseKeyName(%q) = %#x, want %#x", tt.name, got, tt.key)
+		}
+	}
+}
+
+func TestParseKeyNameErrors(t *testing.T) {
+	for _, name := range []string{"", "Foo", "Ctrl-Foo", "Alt-"} {
+		_, err := parseKeyName(name)
+		if !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("parseKeyName(%q) error = %v, want %v", name, err, ErrUnknownKey)
+		}
+	}
+}
@@ -6,7 +6,8 @@ import (
 )
 
 const (
-	LEFT_MARGIN = 6
+	LEFT_MARGIN    = 6
+	PALETTE_PROMPT = "> "
 )
 
 type View struct {
@@ -36,29 +37,69 @@ func (v *View) updateSize(rows, cols int) {
 	v.cols = cols
 }
 
+// frame is the editor state drawn by a single Render
+type frame struct {
+	mode      EditorMode
+	doc       *Document
+	cfg       *Config
+	cur       *Cursor
+	finder    *Finder
+	palette   *Palette
+	prompt    *Prompt
+	cmds      *CommandRegistry
+	marked    *lineRange
+	block     *blockRect
+	match     *position // bracket matching the one at the cursor
+	cursors   []position
+	ring      *KillRing
+	recording string
+	message   []string
+	status    string
+}
+
 // Render is the main entry point
-func (v *View) Render(mode EditorMode, doc *Document, cfg *Config, cur *Cursor, finder *Finder, cmds *CommandRegistry, bufferLen int, status string) {
+func (v *View) Render(f *frame) {
 	fmt.Print(HIDE_CURSOR + TOP_LEFT)
-	fmt.Print(v.drawContent(mode, doc, cfg, cur, finder, cmds, bufferLen, status))
-	row, col := cur.screenCoords()
+	fmt.Print(v.drawContent(f))
+	row, col := f.cur.screenCoords()
+	switch f.mode {
+	case PaletteMode:
+		row, col = v.rows-1, len(PALETTE_PROMPT)+len([]rune(f.palette.query))+1
+	case PromptMode:
+		row, col = v.rows-1, len([]rune(f.prompt.label))+f.prompt.pos+1
+	}
 	fmt.Printf("\x1b[%d;%dH%s", row, col, SHOW_CURSOR)
 }
 
-func (v *View) drawContent(mode EditorMode, doc *Document, cfg *Config, cur *Cursor, finder *Finder, cmds *CommandRegistry, bufferLen int, status string) string {
+func (v *View) drawContent(f *frame) string {
 	var builder strings.Builder
 	visibleRows := v.rows - v.bottomMargin
 
+	var overlay []string
+	if f.mode == PaletteMode {
+		overlay = v.drawPalette(f.palette, visibleRows)
+	} else if len(f.message) > 0 {
+		overlay = v.drawMessage(f.message, visibleRows)
+	}
+	overlayStart := visibleRows - len(overlay)
+
 	for screenRow := 0; screenRow < visibleRows; screenRow++ {
+		if screenRow >= overlayStart {
+			builder.WriteString(overlay[screenRow-overlayStart])
+			builder.WriteString(CLEAR_RIGHT + RESET + "\r\n")
+			continue
+		}
 		docRow := v.rowOffset + screenRow
-		lineText := v.renderLine(doc, docRow, cfg)
+		lineText := v.renderLine(f, docRow)
 		builder.WriteString(lineText)
 		builder.WriteString(CLEAR_RIGHT + "\r\n")
 	}
-	builder.WriteString(v.makeFooter(mode, doc, cfg, cur, finder, cmds, bufferLen, status))
+	builder.WriteString(v.makeFooter(f))
 	return builder.String()
 }
 
-func (v *View) renderLine(doc *Document, row int, cfg *Config) string {
+func (v *View) renderLine(f *frame, row int) string {
+	doc, cfg, cur, block, match := f.doc, f.cfg, f.cur, f.block, f.match
 	sideWidth := v.leftMargin - 1
 	if row >= doc.lineCount() {
 		return fmt.Sprintf("%s~", strings.Repeat(" ", sideWidth-1))
@@ -68,34 +109,162 @@ func (v *View) renderLine(doc *Document, row int, cfg *Config) string {
 		lineNum = "~"
 	}
 	padding := strings.Repeat(" ", sideWidth-len(lineNum))
-	return padding + lineNum + " " + doc.lines[row].render
+
+	highlight := f.marked != nil && f.marked.contains(row)
+	base := ""
+	if highlight {
+		base = BLACK_ON_GREY
+	}
+	content := doc.lines[row].content
+	styles := map[int]string{}
+	if cfg.MaxLineLength > 0 {
+		// mark text beyond max_line_length
+		width := len([]rune(doc.lines[row].render))
+		for col := cfg.MaxLineLength; col < width; col++ {
+			styles[col] = HIGHLIGHT_OVERFLOW
+		}
+	}
+	if block != nil && block.contains(row) {
+		for col := block.left; col < block.right; col++ {
+			styles[col] = BLACK_ON_GREY
+		}
+	}
+	if match != nil && match.row == row {
+		styles[cur.calculateRenderCol(content, cfg.TabSize, match.col)] = HIGHLIGHT_BRACKET
+	}
+	for _, c := range f.cursors {
+		if c.row == row {
+			styles[cur.calculateRenderCol(content, cfg.TabSize, c.col)] = REVERSE
+		}
+	}
+	render := highlightCells(doc.lines[row].render, styles, base)
+	if highlight {
+		return padding + lineNum + " " + base + render + RESET
+	}
+	return padding + lineNum + " " + render
 }
 
-func (v *View) makeFooter(mode EditorMode, doc *Document, cfg *Config, cur *Cursor, finder *Finder, cmds *CommandRegistry, bufferLen int, status string) string {
+// highlightCells styles the runes at the given rendered columns, restoring
+// base after each. Columns past the end of the line style padding spaces
+// so that cursors and blocks beyond it are visible.
+func highlightCells(render string, styles map[int]string, base string) string {
+	if len(styles) == 0 {
+		return render
+	}
+	runes := []rune(render)
+	for col := range styles {
+		for len(runes) <= col {
+			runes = append(runes, ' ')
+		}
+	}
+	var builder strings.Builder
+	for col, r := range runes {
+		style, ok := styles[col]
+		if !ok {
+			builder.WriteRune(r)
+			continue
+		}
+		builder.WriteString(style)
+		builder.WriteRune(r)
+		builder.WriteString(RESET + base)
+	}
+	return builder.String()
+}
+
+// drawMessage renders command output above the footer, keeping the last
+// lines when there are too many to show
+func (v *View) drawMessage(message []string, maxRows int) []string {
+	n := min(len(message), MESSAGE_ROWS, maxRows)
+	rows := make([]string, 0, n)
+	for _, line := range message[len(message)-n:] {
+		runes := []rune(strings.ReplaceAll(line, "\t", " "))
+		if len(runes) > v.cols-1 {
+			runes = runes[:v.cols-1]
+		}
+		rows = append(rows, BLACK_ON_GREY+" "+string(runes))
+	}
+	return rows
+}
+
+// drawPalette renders the palette's matching commands, one per row,
+// highlighting the selected entry
+func (v *View) drawPalette(palette *Palette, maxRows int) []string {
+	n := min(len(palette.matches), PALETTE_ROWS, maxRows)
+	if n == 0 {
+		return []string{BLACK_ON_GREY + " no matching commands"}
+	}
+
+	// scroll the list so the selection stays visible
+	start := max(palette.selected-n+1, 0)
+
+	nameWidth := 0
+	for _, cmd := range palette.matches {
+		nameWidth = max(nameWidth, len(cmd.name))
+	}
+
+	rows := make([]string, 0, n)
+	for i := start; i < start+n; i++ {
+		cmd := palette.matches[i]
+		binding := keyName(cmd.key)
+		entry := []rune(fmt.Sprintf(" %-*s  %s", nameWidth, cmd.name, cmd.desc))
+		if width := max(v.cols-len(binding)-2, 0); len(entry) > width {
+			entry = entry[:width]
+		}
+		padding := max(v.cols-len(entry)-len(binding)-1, 1)
+		color := BLACK_ON_GREY
+		if i == palette.selected {
+			color = BLACK_ON_WHITE
+		}
+		rows = append(rows, color+string(entry)+strings.Repeat(" ", padding)+binding)
+	}
+	return rows
+}
+
+func (v *View) makeFooter(f *frame) string {
+	doc, finder, ring, status := f.doc, f.finder, f.ring, f.status
 	var builder strings.Builder
 	builder.WriteString(BLACK_ON_WHITE)
 
-	switch mode {
+	switch f.mode {
 	case EditMode:
-		builder.WriteString(buildCommandHintLine(cmds))
+		builder.WriteString(buildCommandHintLine(f.cmds, v.cols))
 	case FindMode:
 		builder.WriteString("Ctrl-F: Exit find mode | Enter: Search substring | Next: →↓ | Prev: ←↑ | ")
 		builder.WriteString(fmt.Sprintf("[searching for: %s_]", finder.findString))
 		if finder.numMatches() > 0 {
 			builder.WriteString(fmt.Sprintf(" [match: %d/%d]", finder.current+1, finder.numMatches()))
 		}
+	case PaletteMode:
+		builder.WriteString(PALETTE_PROMPT + f.palette.query)
+	case PromptMode:
+		builder.WriteString(f.prompt.label + f.prompt.text())
 	}
 	builder.WriteString(CLEAR_RIGHT + RESET + "\r\n")
 
-	row, col := cur.coords()
+	row, col := f.cur.coords()
 	dirtyMarker := ""
 	if doc.dirty {
 		dirtyMarker = "*"
 	}
 
-	editorState := fmt.Sprintf("[%d:%d] [lines: %d] [file: %s%s]", row+1, col+1, doc.lineCount(), doc.fileName, dirtyMarker)
-	if bufferLen > 0 {
-		editorState += fmt.Sprintf(" [buffer: %d lines]", bufferLen)
+	editorState := fmt.Sprintf("[%d:%d] [lines: %d] [file: %s%s]", row+1, col+1, doc.lineCount(), doc.displayName(), dirtyMarker)
+	if doc.readonly {
+		editorState += " [read-only]"
+	}
+	if f.marked != nil {
+		editorState += fmt.Sprintf(" [marked: %d lines]", f.marked.count())
+	}
+	if f.block != nil {
+		editorState += fmt.Sprintf(" [block: %dx%d]", f.block.rows(), f.block.width())
+	}
+	if len(f.cursors) > 0 {
+		editorState += fmt.Sprintf(" [cursors: %d]", len(f.cursors)+1)
+	}
+	if ring.len() > 0 {
+		editorState += fmt.Sprintf(" [kill ring: %d/%d, %d lines]", ring.yankIdx+1, ring.len(), len(ring.entries[ring.yankIdx]))
+	}
+	if f.recording != "" {
+		editorState += fmt.Sprintf(" [recording: %s]", f.recording)
 	}
 	center := fmt.Sprintf("gtext v%s", v.version)
 
@@ -113,6 +282,12 @@ func (v *View) makeFooter(mode EditorMode, doc *Document, cfg *Config, cur *Curs
 	return builder.String()
 }
 
+// centerOn scrolls so that row is in the middle of the screen
+func (v *View) centerOn(row, totalLines int) {
+	visibleRows := v.rows - v.topMargin - v.bottomMargin
+	v.rowOffset = max(min(row-visibleRows/2, totalLines-visibleRows), 0)
+}
+
 func (v *View) updateScroll(cursorRow, totalLines int) {
 	for {
 		screenY := cursorRow - v.rowOffset
@@ -135,11 +310,19 @@ func (v *View) updateScroll(cursorRow, totalLines int) {
 	}
 }
 
-func buildCommandHintLine(cr *CommandRegistry) string {
-	var parts []string
-	for _, key := range cr.order {
-		cmd := cr.cmds[key]
-		parts = append(parts, fmt.Sprintf("%s: %s", cmd.name, cmd.desc))
+// buildCommandHintLine lists key bound commands, starting with the palette,
+// for as long as they fit in width
+func buildCommandHintLine(cr *CommandRegistry, width int) string {
+	hint := fmt.Sprintf("%s: commands", keyName(cr.keyFor("command-palette")))
+	for _, cmd := range cr.all() {
+		if cmd.key == 0 || cmd.name == "command-palette" {
+			continue
+		}
+		part := fmt.Sprintf(" | %s: %s", keyName(cmd.key), cmd.desc)
+		if len(hint)+len(part) > width {
+			break
+		}
+		hint += part
 	}
-	return strings.Join(parts, " | ")
+	return hint
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// markdownHardBreak reports whether a line ends in the two or more
+// spaces that Markdown renders as a line break
+func markdownHardBreak(content string) bool {
+	return strings.HasSuffix(content, "  ") && strings.TrimSpace(content) != ""
+}
+
+// trimTrailingWhitespace strips whitespace from the end of every line
+// except those keep reports on
+func trimTrailingWhitespace(lines []string, keep func(row int, content string) bool) []string {
+	trimmed := make([]string, len(lines))
+	for row, content := range lines {
+		if keep(row, content) {
+			trimmed[row] = content
+			continue
+		}
+		trimmed[row] = strings.TrimRightFunc(content, unicode.IsSpace)
+	}
+	return trimmed
+}
+
+// trimFinalBlankLines drops blank and whitespace-only lines at the end so
+// that the file ends with exactly one newline
+func trimFinalBlankLines(lines []string) []string {
+	end := len(lines)
+	for end > 1 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return slices.Clone(lines[:end])
+}
+
+// convertIndentation rewrites the leading whitespace of every line as
+// spaces or tabs, keeping its rendered width
+func convertIndentation(lines []string, tabSize int, expandTabs bool) []string {
+	converted := make([]string, len(lines))
+	for row, content := range lines {
+		indent := leadingWhitespace(content)
+		width := 0
+		for _, r := range indent {
+			if r == TAB {
+				width += tabSize - width%tabSize
+			} else {
+				width++
+			}
+		}
+		var newIndent string
+		if expandTabs {
+			newIndent = strings.Repeat(" ", width)
+		} else {
+			newIndent = strings.Repeat("\t", width/tabSize) + strings.Repeat(" ", width%tabSize)
+		}
+		converted[row] = newIndent + content[len(indent):]
+	}
+	return converted
+}
+
+// trimLines applies trimTrailingWhitespace with the exceptions configured
+// in cfg
+func (e *Editor) trimLines(lines []string, path string, cfg *Config, skipCursorLine bool) []string {
+	markdown := detectLanguage(path).name == "markdown"
+	return trimTrailingWhitespace(lines, func(row int, content string) bool {
+		if skipCursorLine && row == e.cursor.row {
+			return true
+		}
+		return markdown && cfg.KeepMarkdownBreaks && markdownHardBreak(content)
+	})
+}
+
+// normalizeOnSave applies the whitespace fixes enabled in cfg before the
+// document is written to path
+func (e *Editor) normalizeOnSave(path string, cfg *Config) error {
+	lines := e.document.contents()
+	if cfg.TrimTrailingWhitespace {
+		lines = e.trimLines(lines, path, cfg, cfg.TrimSkipCursorLine)
+	}
+	if cfg.FinalNewline {
+		lines = trimFinalBlankLines(lines)
+	}
+	if cfg.ConvertIndentation {
+		lines = convertIndentation(lines, cfg.TabSize, cfg.ExpandTabs)
+	}
+	return e.applyLines(lines)
+}
+
+// prepareSave normalizes and formats the document with the settings cfg
+// has for path, then calls save
+func (e *Editor) prepareSave(path string, cfg *Config, save func()) {
+	err := e.normalizeOnSave(path, cfg)
+	if e.handleError("failed to normalize whitespace", err) {
+		return
+	}
+	e.formatThen(path, cfg, save)
+}
+
+// normalize applies a whitespace fix to the whole document now
+func (e *Editor) normalize(fix func([]string) []string, what string) error {
+	before := e.document.contents()
+	after := fix(before)
+	changed := 0
+	for _, h := range diffHunks(before, after) {
+		changed += max(h.end-h.start, len(h.lines))
+	}
+	err := e.applyLines(after)
+	if err != nil {
+		return err
+	}
+	e.setStatus(fmt.Sprintf("%s: %d lines changed", what, changed), 2)
+	return nil
+}
+
+func (e *Editor) execTrimWhitespace(args cmdArgs) error {
+	return e.normalize(func(lines []string) []string {
+		return e.trimLines(lines, e.document.fileName, e.document.config, false)
+	}, "trimmed trailing whitespace")
+}
+
+func (e *Editor) execFinalNewline(args cmdArgs) error {
+	return e.normalize(trimFinalBlankLines, "removed final blank lines")
+}
+
+func (e *Editor) execRetab(args cmdArgs) error {
+	return e.normalize(func(lines []string) []string {
+		return convertIndentation(lines, e.document.config.TabSize, e.document.config.ExpandTabs)
+	}, "converted indentation")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTrimFinalBlankLines(t *testing.T) {
+	tests := []struct {
+		lines, want []string
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"a", "", ""}, []string{"a"}},
+		{[]string{"a", "  ", "\t"}, []string{"a"}},
+		{[]string{"a", "", "b", ""}, []string{"a", "", "b"}},
+		{[]string{"", ""}, []string{""}},
+		{[]string{""}, []string{""}},
+	}
+	for _, tt := range tests {
+		if got := trimFinalBlankLines(tt.lines); !slices.Equal(got, tt.want) {
+			t.Errorf("trimFinalBlankLines(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestTrimTrailingWhitespace(t *testing.T) {
+	lines := []string{"a  ", "b\t", "c ", "  "}
+	keep := func(row int, content string) bool { return row == 2 }
+	got := trimTrailingWhitespace(lines, keep)
+	want := []string{"a", "b", "c ", ""}
+	if !slices.Equal(got, want) {
+		t.Errorf("trimTrailingWhitespace(%q) = %q, want %q", lines, got, want)
+	}
+}
+
+func TestConvertIndentation(t *testing.T) {
+	tests := []struct {
+		line       string
+		expandTabs bool
+		want       string
+	}{
+		{"\tx", true, "    x"},
+		{"\t\tx", true, "        x"},
+		{"  \tx", true, "    x"},
+		{"\t  x", true, "      x"},
+		{"    x", false, "\tx"},
+		{"      x", false, "\t  x"},
+		{"  \tx", false, "\tx"},
+		{"  x", false, "  x"},
+		{"x\t y", true, "x\t y"},
+		{"", true, ""},
+	}
+	for _, tt := range tests {
+		got := convertIndentation([]string{tt.line}, 4, tt.expandTabs)
+		if got[0] != tt.want {
+			t.Errorf("convertIndentation(%q, 4, %v) = %q, want %q", tt.line, tt.expandTabs, got[0], tt.want)
+		}
+	}
+}
+
+func TestMarkdownHardBreak(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"text  ", true},
+		{"text ", false},
+		{"text", false},
+		{"    ", false},
+	}
+	for _, tt := range tests {
+		if got := markdownHardBreak(tt.line); got != tt.want {
+			t.Errorf("markdownHardBreak(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeOnSave(t *testing.T) {
+	e := newTestEditor(t, "notes.md", "# title  \n\tcode \nend\n\n  \n")
+	e.document.config.TrimTrailingWhitespace = true
+	e.document.config.FinalNewline = true
+	e.document.config.ConvertIndentation = true
+	e.document.config.ExpandTabs = true
+	err := e.normalizeOnSave("notes.md", e.document.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(docLines(e.document), "\n")
+	want := "# title  \n    code\nend"
+	if got != want {
+		t.Errorf("normalizeOnSave() = %q, want %q", got, want)
+	}
+}
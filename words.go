@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+type runeClass byte
+
+const (
+	classSpace runeClass = iota
+	classWord
+	classIdeograph
+	classPunct
+)
+
+// classify groups runes for word movement. It follows the Unicode word
+// segmentation rules that matter in source and prose: letters, digits,
+// combining marks and connector punctuation such as '_' form one word,
+// every ideograph is a word of its own, and runs of other symbols are
+// treated as a word.
+func classify(r rune) runeClass {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar):
+		return classIdeograph
+	case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r), unicode.Is(unicode.Pc, r):
+		return classWord
+	default:
+		return classPunct
+	}
+}
+
+// wordStartBefore returns the column of the start of the word before col,
+// skipping any whitespace in between
+func wordStartBefore(runes []rune, col int) int {
+	col = min(col, len(runes))
+	for col > 0 && classify(runes[col-1]) == classSpace {
+		col--
+	}
+	if col == 0 {
+		return 0
+	}
+	class := classify(runes[col-1])
+	col--
+	if class == classIdeograph {
+		return col
+	}
+	for col > 0 && classify(runes[col-1]) == class {
+		col--
+	}
+	return col
+}
+
+// wordEndAfter returns the column just past the end of the word at or
+// after col, skipping any whitespace before it
+func wordEndAfter(runes []rune, col int) int {
+	col = max(col, 0)
+	for col < len(runes) && classify(runes[col]) == classSpace {
+		col++
+	}
+	if col == len(runes) {
+		return col
+	}
+	class := classify(runes[col])
+	col++
+	if class == classIdeograph {
+		return col
+	}
+	for col < len(runes) && classify(runes[col]) == class {
+		col++
+	}
+	return col
+}
+
+// isBlank reports whether a line contains only whitespace
+func isBlank(content string) bool {
+	for _, r := range content {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// leadingWhitespace returns the indentation at the start of a line
+func leadingWhitespace(content string) string {
+	trimmed := strings.TrimLeftFunc(content, unicode.IsSpace)
+	return content[:len(content)-len(trimmed)]
+}
+
+func firstRune(s string) (rune, bool) {
+	for _, r := range s {
+		return r, true
+	}
+	return 0, false
+}
+
+func lastRune(s string) (rune, bool) {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0, false
+	}
+	return runes[len(runes)-1], true
+}
+
+func isOpener(r rune) bool {
+	return r == '(' || r == '[' || r == '{'
+}
+
+func isCloser(r rune) bool {
+	return r == ')' || r == ']' || r == '}'
+}
+
+// closerFor returns the closing bracket matching an opening one
+func closerFor(r rune) rune {
+	switch r {
+	case '(':
+		return ')'
+	case '[':
+		return ']'
+	case '{':
+		return '}'
+	}
+	return 0
+}
+
+// openerFor returns the opening bracket matching a closing one
+func openerFor(r rune) rune {
+	switch r {
+	case ')':
+		return '('
+	case ']':
+		return '['
+	case '}':
+		return '{'
+	}
+	return 0
+}
+
+// isWordRune reports whether r is part of an identifier or word
+func isWordRune(r rune) bool {
+	return r != 0 && classify(r) == classWord
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestWordStartBefore(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		want int
+	}{
+		{"hello world", 11, 6},
+		{"hello world", 8, 6},
+		{"hello world", 6, 0},
+		{"hello   world", 8, 0},
+		{"hello", 0, 0},
+		{"hello", 99, 0},
+		{"   ", 3, 0},
+		{"foo_bar2 x", 8, 0},
+		{"a.b", 3, 2},
+		{"a := b", 4, 2},
+		{"x == y", 4, 2},
+		{"héllo wörld", 11, 6},
+		{"日本語", 3, 2},
+		{"日本語", 2, 1},
+		{"abc日本", 3, 0},
+	}
+	for _, tt := range tests {
+		if got := wordStartBefore([]rune(tt.line), tt.col); got != tt.want {
+			t.Errorf("wordStartBefore(%q, %d) = %d, want %d", tt.line, tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestWordEndAfter(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		want int
+	}{
+		{"hello world", 0, 5},
+		{"hello world", 2, 5},
+		{"hello world", 5, 11},
+		{"hello   world", 5, 13},
+		{"hello", 5, 5},
+		{"hello", -1, 5},
+		{"   ", 0, 3},
+		{"foo_bar2 x", 0, 8},
+		{"a.b", 1, 2},
+		{"a := b", 1, 4},
+		{"héllo wörld", 5, 11},
+		{"日本語", 0, 1},
+		{"abc日本", 0, 3},
+	}
+	for _, tt := range tests {
+		if got := wordEndAfter([]rune(tt.line), tt.col); got != tt.want {
+			t.Errorf("wordEndAfter(%q, %d) = %d, want %d", tt.line, tt.col, got, tt.want)
+		}
+	}
+}